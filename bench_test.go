@@ -175,3 +175,75 @@ func BenchmarkMap(b *testing.B) {
 		sinkInt = Count(Map(s, double))
 	}
 }
+
+func BenchmarkSum(b *testing.B) {
+	s := benchSeq()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = Sum(s)
+	}
+}
+
+func BenchmarkSumSlice(b *testing.B) {
+	// Baseline for BenchmarkSum: the same values summed with a plain slice loop, without per-element yield calls.
+	s := benchInts()
+	b.ReportAllocs()
+	for b.Loop() {
+		var sum int
+		for _, v := range s {
+			sum += v
+		}
+		sinkInt = sum
+	}
+}
+
+func BenchmarkTopN(b *testing.B) {
+	s := benchSeq()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = TopN(s, 10)[0]
+	}
+}
+
+func BenchmarkSortedTake(b *testing.B) {
+	s := benchShuffled()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = Count(Take(Sorted(s), 10))
+	}
+}
+
+func BenchmarkSortedIncrementalTake(b *testing.B) {
+	// Same as BenchmarkSortedTake, but only the first 10 elements are ordered.
+	s := benchShuffled()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = Count(Take(SortedIncremental(s), 10))
+	}
+}
+
+// benchShuffled returns the benchmark values in a fixed pseudo-random order.
+func benchShuffled() iter.Seq[int] {
+	s := benchInts()
+	for i := range s {
+		j := (i * 7919) % len(s)
+		s[i], s[j] = s[j], s[i]
+	}
+	return slices.Values(s)
+}
+
+func BenchmarkCollect(b *testing.B) {
+	s := benchSeq()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = len(slices.Collect(s))
+	}
+}
+
+func BenchmarkCollectCap(b *testing.B) {
+	s := benchSeq()
+	b.ReportAllocs()
+	for b.Loop() {
+		sinkInt = len(CollectCap(s, benchN))
+	}
+}
@@ -0,0 +1,159 @@
+// Package pipeline runs a sequence through a chain of stages, each on its own goroutine, with structured teardown: the
+// first stage to fail cancels the others, and Run returns only once every goroutine has exited.
+//
+// Composing goroutine-backed stages by hand, with seq.ToChanCtx and seq.FromChan, leaks goroutines when a middle stage
+// fails, because nothing tells the stages on either side of it to stop. A Pipeline owns those goroutines and the
+// context that stops them.
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// Stage is one step of a [Pipeline]. It ranges over its input, calling yield for each element it produces, and returns
+// when the input is exhausted or yield returns false, which happens once the pipeline is being torn down. Returning a
+// non-nil error fails the pipeline. A Stage should also return promptly when ctx is done.
+type Stage[T any] func(ctx context.Context, in iter.Seq[T], yield func(T) bool) error
+
+// Pipeline is a source sequence and the stages its elements pass through. Create one with [New] and add stages with
+// Then. Stages can only keep the element type, because methods can't introduce type parameters; use functions from
+// github.com/freeformz/seq, such as seq.Map, to change the type of the source beforehand.
+type Pipeline[T any] struct {
+	src    iter.Seq[T]
+	stages []Stage[T]
+}
+
+// New returns a Pipeline reading from the provided sequence.
+func New[T any](src iter.Seq[T]) *Pipeline[T] {
+	return &Pipeline[T]{src: src}
+}
+
+// Then adds a stage to the end of the pipeline and returns the pipeline, for chaining.
+func (p *Pipeline[T]) Then(stage Stage[T]) *Pipeline[T] {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Map returns a [Stage] that replaces each element with the result of fn, failing the pipeline if fn returns an error.
+func Map[T any](fn func(context.Context, T) (T, error)) Stage[T] {
+	return func(ctx context.Context, in iter.Seq[T], yield func(T) bool) error {
+		for t := range in {
+			o, err := fn(ctx, t)
+			if err != nil {
+				return err
+			}
+			if !yield(o) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// Filter returns a [Stage] that keeps the elements for which fn returns true, failing the pipeline if fn returns an
+// error.
+func Filter[T any](fn func(context.Context, T) (bool, error)) Stage[T] {
+	return func(ctx context.Context, in iter.Seq[T], yield func(T) bool) error {
+		for t := range in {
+			keep, err := fn(ctx, t)
+			if err != nil {
+				return err
+			}
+			if keep && !yield(t) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// Run runs the pipeline: the source and every stage run on their own goroutines, connected by unbuffered channels, and
+// sink is called, on the calling goroutine, with each element leaving the last stage. A nil sink discards the
+// elements. The first error returned by a stage or the sink cancels the context passed to the stages and is returned
+// once every goroutine has exited. If parent is canceled first, Run tears the pipeline down in the same way and
+// returns parent.Err(). Otherwise Run returns nil once the source is exhausted and every element has reached the sink.
+// A Pipeline may be run more than once if its source can be iterated over more than once.
+func (p *Pipeline[T]) Run(parent context.Context, sink func(T) error) error {
+	ctx, cancel := context.WithCancel(parent)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	// tear down even if sink panics; on the normal path this has already happened
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	send := func(ch chan<- T) func(T) bool {
+		return func(t T) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- t:
+				return true
+			}
+		}
+	}
+	recv := func(ch <-chan T) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case t, ok := <-ch:
+					if !ok || !yield(t) {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	src := make(chan T)
+	wg.Go(func() {
+		defer close(src)
+		yield := send(src)
+		for t := range p.src {
+			if !yield(t) {
+				return
+			}
+		}
+	})
+	last := src
+	for _, stage := range p.stages {
+		in, out := last, make(chan T)
+		wg.Go(func() {
+			defer close(out)
+			if err := stage(ctx, recv(in), send(out)); err != nil {
+				fail(err)
+			}
+		})
+		last = out
+	}
+
+	for t := range recv(last) {
+		if sink == nil {
+			continue
+		}
+		if err := sink(t); err != nil {
+			fail(err)
+			break
+		}
+	}
+	cancel()
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return parent.Err()
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+)
+
+func ExamplePipeline() {
+	parse := Map(func(_ context.Context, s string) (string, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n * n), nil
+	})
+	nonZero := Filter(func(_ context.Context, s string) (bool, error) { return s != "0", nil })
+
+	var got []string
+	err := New(slices.Values([]string{"3", "0", "4"})).
+		Then(parse).
+		Then(nonZero).
+		Run(context.Background(), func(s string) error {
+			got = append(got, s)
+			return nil
+		})
+	fmt.Println(got, err)
+
+	// Output:
+	// [9 16] <nil>
+}
+
+func ExamplePipeline_Run_error() {
+	// the failing stage stops the endless source and every other stage before Run returns
+	naturals := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+	errTooBig := errors.New("too big")
+	check := Map(func(_ context.Context, v int) (int, error) {
+		if v > 100 {
+			return 0, errTooBig
+		}
+		return v, nil
+	})
+
+	err := New(iter.Seq[int](naturals)).Then(check).Run(context.Background(), nil)
+	fmt.Println(err, errors.Is(err, errTooBig))
+
+	// Output:
+	// too big true
+}
+
+func ExampleStage() {
+	// a stage can emit any number of elements per input, here a running total every two elements
+	pairSums := Stage[int](func(_ context.Context, in iter.Seq[int], yield func(int) bool) error {
+		sum, n := 0, 0
+		for v := range in {
+			sum += v
+			if n++; n%2 == 0 && !yield(sum) {
+				return nil
+			}
+		}
+		return nil
+	})
+
+	err := New(slices.Values([]int{1, 2, 3, 4, 5})).Then(pairSums).Run(context.Background(), func(v int) error {
+		fmt.Println(v)
+		return nil
+	})
+	fmt.Println(err)
+
+	// Output:
+	// 3
+	// 10
+	// <nil>
+}
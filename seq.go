@@ -1,9 +1,30 @@
 package seq
 
 import (
+	"bufio"
+	"bytes"
 	"cmp"
+	"compress/gzip"
 	"context"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+	"io"
 	"iter"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -728,31 +749,72 @@ func IsSortedKV[K, V cmp.Ordered](seq iter.Seq2[K, V]) bool {
 	return true
 }
 
-// Coalesce returns the first non zero value in the sequence. The provided sequence is iterated over when Coalesce is
-// called, stopping at the first non-zero value. If no non-zero value is found, the second return value is false.
+// Coalesce returns the first non zero value in the sequence. A value whose type has an IsZero() bool method, such as
+// [time.Time], is zero if the method returns true; otherwise it is zero if it equals the zero value of T. The provided
+// sequence is iterated over when Coalesce is called, stopping at the first non-zero value. If no non-zero value is
+// found, the second return value is false.
 func Coalesce[T comparable](seq iter.Seq[T]) (T, bool) {
-	var zero T
-	for t := range seq {
-		if t != zero {
-			return t, true
-		}
-	}
-	return zero, false
+	return CoalesceFunc(seq, isZeroComparable[T])
 }
 
-// CoalesceKV returns the first key-value pair in the sequence whose value is non zero. The provided sequence is
-// iterated over when CoalesceKV is called, stopping at the first non-zero value. If no non-zero value is found, the
-// second return value is false.
+// CoalesceKV returns the first key-value pair in the sequence whose value is non zero, judged as [Coalesce] does. The
+// provided sequence is iterated over when CoalesceKV is called, stopping at the first non-zero value. If no non-zero
+// value is found, the second return value is false.
 func CoalesceKV[K, V comparable](seq iter.Seq2[K, V]) (KV[K, V], bool) {
-	var zero V
 	for k, v := range seq {
-		if v != zero {
+		if !isZeroComparable(v) {
 			return KV[K, V]{K: k, V: v}, true
 		}
 	}
 	return KV[K, V]{}, false
 }
 
+// CoalesceFunc returns the first value in the sequence for which isZero returns false. Use [IsZero] for types that
+// aren't comparable. The provided sequence is iterated over when CoalesceFunc is called, stopping at the first non-zero
+// value. If no non-zero value is found, the second return value is false.
+func CoalesceFunc[T any](seq iter.Seq[T], isZero func(T) bool) (T, bool) {
+	for t := range seq {
+		if !isZero(t) {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// IsZero reports whether t is zero. If T has an IsZero() bool method, such as [time.Time], its result is used;
+// otherwise t is zero if [reflect.Value.IsZero] says so. A nil pointer is always zero, even if its type has the method,
+// such as *time.Time. It works for any type, including structs holding slices or maps, and can be passed to
+// [CoalesceFunc].
+func IsZero[T any](t T) bool {
+	if z, ok := zeroer(t); ok {
+		return z
+	}
+	return reflect.ValueOf(&t).Elem().IsZero()
+}
+
+// isZeroComparable is like IsZero for comparable types, comparing against the zero value instead of using reflection.
+func isZeroComparable[T comparable](t T) bool {
+	if z, ok := zeroer(t); ok {
+		return z
+	}
+	var zero T
+	return t == zero
+}
+
+// zeroer returns the result of t's IsZero method, and whether it has one. The method isn't called on a nil pointer,
+// which would panic if it is the promoted value method of the pointed-to type; a nil pointer counts as zero.
+func zeroer(t any) (bool, bool) {
+	z, ok := t.(interface{ IsZero() bool })
+	if !ok {
+		return false, false
+	}
+	if v := reflect.ValueOf(t); v.Kind() == reflect.Pointer && v.IsNil() {
+		return true, true
+	}
+	return z.IsZero(), true
+}
+
 // Count returns the number of elements in the sequence. The sequence is iterated over before Count returns.
 func Count[T any](seq iter.Seq[T]) int {
 	var count int
@@ -1423,9 +1485,13 @@ func NoneKV[K, V any](seq iter.Seq2[K, V], fn func(K, V) bool) bool {
 // Number is the constraint used by the numeric aggregation functions [Sum], [Product], and [Average]. It permits any
 // integer or floating point type.
 type Number interface {
+	Integer | ~float32 | ~float64
+}
+
+// Integer is the constraint used by [Range] and [RangeStep]. It permits any integer type.
+type Integer interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
-		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
-		~float32 | ~float64
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
 }
 
 // Sum returns the sum of the values in the sequence, or zero if the sequence is empty. The sequence is iterated over
@@ -1644,3 +1710,2997 @@ func Enumerate[T any](seq iter.Seq[T]) iter.Seq2[int, T] {
 		}
 	}
 }
+
+// SharedSeq divides the elements of a single sequence among any number of concurrent consumers. See [Shared].
+type SharedSeq[T any] struct {
+	mu   sync.Mutex
+	next func() (T, bool)
+	stop func()
+	done bool
+}
+
+// Shared returns a SharedSeq that hands out the elements of the provided sequence to every sequence returned by its
+// Seq method. The sequences returned by Seq may be iterated concurrently from multiple goroutines; each element of the
+// provided sequence is yielded to exactly one of them (work-stealing), so together they yield every element once. A
+// consumer that stops early only stops itself: the remaining elements go to the other consumers. The provided sequence
+// is iterated over lazily as the consumers pull elements and at most once in total. Call Stop to release the provided
+// sequence if the consumers stop before it is exhausted.
+func Shared[T any](seq iter.Seq[T]) *SharedSeq[T] {
+	next, stop := iter.Pull(seq)
+	return &SharedSeq[T]{next: next, stop: stop}
+}
+
+// Seq returns a sequence that yields the next unclaimed elements of the shared sequence. It ends when the shared
+// sequence is exhausted or stopped.
+func (s *SharedSeq[T]) Seq() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			t, ok := s.pull()
+			if !ok || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Stop ends the shared sequence, releasing the provided sequence. Sequences returned by Seq end once their current
+// element has been yielded. It is safe to call Stop more than once and concurrently with the consumers.
+func (s *SharedSeq[T]) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.stop()
+}
+
+func (s *SharedSeq[T]) pull() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		var z T
+		return z, false
+	}
+	t, ok := s.next()
+	if !ok {
+		s.done = true
+	}
+	return t, ok
+}
+
+// MergeAdjacentKV returns a sequence that collapses each run of adjacent key-value pairs with equal keys into a single
+// pair, folding the values of the run together with combine in encounter order. Keys that are equal but not adjacent
+// are not merged; sort or group the sequence first if that's needed. The provided sequence is iterated over lazily when
+// the returned sequence is iterated over.
+func MergeAdjacentKV[K comparable, V any](seq iter.Seq2[K, V], combine func(V, V) V) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var cur KV[K, V]
+		first := true
+		for k, v := range seq {
+			switch {
+			case first:
+				first = false
+				cur.K, cur.V = k, v
+			case k == cur.K:
+				cur.V = combine(cur.V, v)
+			default:
+				if !yield(cur.K, cur.V) {
+					return
+				}
+				cur.K, cur.V = k, v
+			}
+		}
+		if !first {
+			yield(cur.K, cur.V)
+		}
+	}
+}
+
+// Case is a named branch used by [Switch]. An element matches the case if Match returns true for it. A Case with a nil
+// Match matches every element, so it can be used as the default case by placing it last.
+type Case[T any] struct {
+	Name  string
+	Match func(T) bool
+}
+
+// Switch routes each element of the sequence to the first case it matches and returns a sequence per case name.
+// Elements that match no case are dropped. Cases sharing a name share a sequence. The provided sequence is iterated
+// over only once, so a single-use source, such as a channel or a reader, can feed every route: like [Split], a returned
+// sequence that needs its next element pulls from the provided sequence, buffering the elements routed to the others
+// until they yield them, so memory grows with how unevenly they are consumed. Elements routed to a returned sequence
+// that has stopped are dropped. The provided sequence is iterated over lazily when the first returned sequence is
+// iterated over, and released once all of them have stopped or finished. The returned sequences may be iterated over
+// concurrently, but each only once.
+func Switch[T any](seq iter.Seq[T], cases []Case[T]) map[string]iter.Seq[T] {
+	index := make(map[string]int, len(cases))
+	for _, c := range cases {
+		if _, ok := index[c.Name]; !ok {
+			index[c.Name] = len(index)
+		}
+	}
+	d := newDealer(seq, len(index), func(d *dealer[T], _ int, t T) {
+		for _, c := range cases {
+			if c.Match == nil || c.Match(t) {
+				if i := index[c.Name]; d.live[i] {
+					d.queues[i] = append(d.queues[i], t)
+				}
+				return
+			}
+		}
+	})
+	branches := d.branches()
+	routes := make(map[string]iter.Seq[T], len(index))
+	for name, i := range index {
+		routes[name] = branches[i]
+	}
+	return routes
+}
+
+// Heartbeat returns a sequence that yields the elements of the provided sequence and additionally yields hb whenever
+// no element has arrived for the duration every. The interval restarts after each element or heartbeat is yielded. The
+// sequence ends when the provided sequence is exhausted or the context is canceled. The provided sequence is iterated
+// over in a separate goroutine when the returned sequence is iterated over; if the consumer stops early, that goroutine
+// exits once the provided sequence yields its next element. The duration every must be greater than zero; if not, the
+// function will panic.
+func Heartbeat[T any](ctx context.Context, seq iter.Seq[T], every time.Duration, hb T) iter.Seq[T] {
+	if every <= 0 {
+		panic("seq: Heartbeat interval must be positive")
+	}
+	return func(yield func(T) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		ch := ToChanCtx(ctx, seq)
+		timer := time.NewTimer(every)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t, ok := <-ch:
+				if !ok || !yield(t) {
+					return
+				}
+			case <-timer.C:
+				if !yield(hb) {
+					return
+				}
+			}
+			timer.Reset(every)
+		}
+	}
+}
+
+// AvgDuration returns the mean of the durations in the sequence, truncated to a whole duration. If the sequence is
+// empty, the second return value is false. Use [Sum] for the total of a sequence of durations. The sequence is iterated
+// over before AvgDuration returns.
+func AvgDuration(seq iter.Seq[time.Duration]) (time.Duration, bool) {
+	var sum time.Duration
+	var count int
+	for d := range seq {
+		sum += d
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / time.Duration(count), true
+}
+
+// TakeWithinBudget returns a sequence of the values of the leading key-value pairs whose keys, the cost of each value,
+// add up to no more than budget. The sequence ends before the first value whose cost would take the running total over
+// budget. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func TakeWithinBudget[T any](seq iter.Seq2[time.Duration, T], budget time.Duration) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		var spent time.Duration
+		for cost, t := range seq {
+			spent += cost
+			if spent > budget || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+var strict atomic.Bool
+
+// SetStrict enables or disables strict mode, which makes sequences wrapped by [SingleUse] panic when they are iterated
+// more than once. Strict mode is disabled by default; enabling it in tests catches single-use sources that are
+// accidentally iterated twice. It is safe to call concurrently.
+func SetStrict(enabled bool) {
+	strict.Store(enabled)
+}
+
+// SingleUse marks the provided sequence as one that may only be iterated over once, like a sequence from [FromChan].
+// When strict mode is enabled with [SetStrict], iterating the returned sequence a second time panics with the name and,
+// if strict mode was enabled at the time, the stack of the first iteration. When strict mode is disabled the returned
+// sequence behaves exactly like the provided one. The provided sequence is iterated over lazily when the returned
+// sequence is iterated over.
+func SingleUse[T any](seq iter.Seq[T], name string) iter.Seq[T] {
+	var mu sync.Mutex
+	var used bool
+	var firstUse []byte
+	return func(yield func(T) bool) {
+		mu.Lock()
+		reused, stack := used, firstUse
+		if !used {
+			used = true
+			if strict.Load() {
+				firstUse = debug.Stack()
+			}
+		}
+		mu.Unlock()
+		if reused && strict.Load() {
+			if stack == nil {
+				stack = []byte("(unavailable: strict mode was disabled)\n")
+			}
+			panic(fmt.Sprintf("seq: single-use sequence %q iterated more than once; first iterated at:\n%s", name, stack))
+		}
+		for t := range seq {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// FlatMapKV maps each key-value pair in the sequence to a key-value sequence with the function and yields the pairs of
+// each resulting sequence in order. Function application happens lazily when the returned sequence is iterated over.
+func FlatMapKV[K, V, K1, V1 any](seq iter.Seq2[K, V], fn func(K, V) iter.Seq2[K1, V1]) iter.Seq2[K1, V1] {
+	return func(yield func(K1, V1) bool) {
+		for k, v := range seq {
+			for k1, v1 := range fn(k, v) {
+				if !yield(k1, v1) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Replay returns a sequence that yields the values of the key-value pairs spaced out in time according to their
+// timestamp keys, scaled by speed: a speed of 2 replays twice as fast as recorded, 0.5 half as fast. The first value is
+// yielded immediately and each later value once its offset from the first timestamp, divided by speed, has elapsed
+// since the first value was yielded. A slow iteratee doesn't shift the schedule: values that are already due are yielded
+// without waiting. Timestamps earlier than the previous one are yielded immediately. The speed must be greater than
+// zero; if not, the function will panic. The provided sequence is iterated over lazily when the returned sequence is
+// iterated over.
+func Replay[T any](seq iter.Seq2[time.Time, T], speed float64) iter.Seq[T] {
+	if speed <= 0 {
+		panic("seq: Replay speed must be positive")
+	}
+	return func(yield func(T) bool) {
+		var first, start time.Time
+		for ts, t := range seq {
+			if start.IsZero() {
+				first, start = ts, time.Now()
+			} else if wait := time.Until(start.Add(time.Duration(float64(ts.Sub(first)) / speed))); wait > 0 {
+				time.Sleep(wait)
+			}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// ToChanClose is like [ToChan] but also returns a stop function. Calling stop makes the producing goroutine close the
+// channel and exit instead of blocking forever on a consumer that has abandoned the channel; values not yet received
+// are discarded. It is safe to call stop more than once and after the sequence is exhausted. If the provided sequence is
+// blocked producing its next value when stop is called, the goroutine exits once that value is produced.
+func ToChanClose[T any](seq iter.Seq[T]) (<-chan T, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return ToChanCtx(ctx, seq), cancel
+}
+
+// ExpireKV returns a sequence with the key-value pairs whose timestamp, as returned by ts for the value, is more than
+// ttl before the current time removed. It is like [ExpireKVAt] using [time.Now] as the clock.
+func ExpireKV[K comparable, V any](seq iter.Seq2[K, V], ts func(V) time.Time, ttl time.Duration) iter.Seq2[K, V] {
+	return ExpireKVAt(seq, ts, ttl, time.Now)
+}
+
+// ExpireKVAt returns a sequence with the key-value pairs whose timestamp, as returned by ts for the value, is more than
+// ttl before the time returned by now removed. The clock is read for every pair, so expiry is judged at iteration time
+// rather than when ExpireKVAt is called. The provided sequence is iterated over lazily when the returned sequence is
+// iterated over.
+func ExpireKVAt[K comparable, V any](seq iter.Seq2[K, V], ts func(V) time.Time, ttl time.Duration, now func() time.Time) iter.Seq2[K, V] {
+	return FilterKV(seq, func(_ K, v V) bool {
+		return now().Sub(ts(v)) <= ttl
+	})
+}
+
+// CycleN is like [Cycle] but yields the elements of the sequence n times in total. If n is not positive, the returned
+// sequence is empty. The provided sequence is iterated over lazily, once per repetition, when the returned sequence is
+// iterated over.
+func CycleN[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for range n {
+			empty := true
+			for t := range seq {
+				empty = false
+				if !yield(t) {
+					return
+				}
+			}
+			if empty {
+				return
+			}
+		}
+	}
+}
+
+// CycleKVN is like [CycleKV] but yields the key-value pairs of the sequence n times in total. If n is not positive, the
+// returned sequence is empty. The provided sequence is iterated over lazily, once per repetition, when the returned
+// sequence is iterated over.
+func CycleKVN[K, V any](seq iter.Seq2[K, V], n int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for range n {
+			empty := true
+			for k, v := range seq {
+				empty = false
+				if !yield(k, v) {
+					return
+				}
+			}
+			if empty {
+				return
+			}
+		}
+	}
+}
+
+// MapWithSummary is like [Map] but also accumulates a summary of the provided elements with acc while the returned
+// sequence is iterated over, so a pipeline can transform elements and report on them in a single pass. The returned
+// function reports the summary of the elements consumed so far; the summary starts over from init each time the
+// returned sequence is iterated over. The returned sequence and function are not safe for concurrent use. Function
+// application happens lazily when the returned sequence is iterated over.
+func MapWithSummary[T, O, S any](seq iter.Seq[T], fn func(T) O, init S, acc func(S, T) S) (iter.Seq[O], func() S) {
+	summary := init
+	mapped := func(yield func(O) bool) {
+		summary = init
+		for t := range seq {
+			summary = acc(summary, t)
+			if !yield(fn(t)) {
+				return
+			}
+		}
+	}
+	return mapped, func() S {
+		return summary
+	}
+}
+
+// Range returns a sequence of the integers from start up to, but not including, end. If end is not greater than start,
+// the returned sequence is empty. Use [RangeStep] for other increments or descending ranges.
+func Range[T Integer](start, end T) iter.Seq[T] {
+	return RangeStep(start, end, 1)
+}
+
+// RangeStep returns a sequence of the integers from start up to, but not including, end, incrementing by step. A
+// negative step counts down from start to end instead. If end can't be reached from start in the direction of step,
+// the returned sequence is empty. The sequence never wraps around when an increment would overflow T. The step must not
+// be zero; if it is, the function will panic.
+func RangeStep[T Integer](start, end, step T) iter.Seq[T] {
+	if step == 0 {
+		panic("seq: RangeStep step must not be zero")
+	}
+	return func(yield func(T) bool) {
+		if step > 0 {
+			for i := start; i < end; {
+				if !yield(i) {
+					return
+				}
+				next := i + step
+				if next <= i { // overflow
+					return
+				}
+				i = next
+			}
+			return
+		}
+		for i := start; i > end; {
+			if !yield(i) {
+				return
+			}
+			next := i + step
+			if next >= i { // overflow
+				return
+			}
+			i = next
+		}
+	}
+}
+
+// SkipUntil returns a sequence that skips the elements of the sequence before the first occurrence of marker and then
+// yields every remaining element, starting with the marker itself. If the marker does not occur, the returned sequence
+// is empty. Use [ResumeAfter] to leave out the marker. The provided sequence is iterated over lazily when the returned
+// sequence is iterated over.
+func SkipUntil[T comparable](seq iter.Seq[T], marker T) iter.Seq[T] {
+	return DropWhile(seq, func(t T) bool {
+		return t != marker
+	})
+}
+
+// ResumeAfter returns a sequence that yields the elements of the sequence after the first occurrence of marker. If the
+// marker does not occur, the returned sequence is empty. The provided sequence is iterated over lazily when the returned
+// sequence is iterated over.
+func ResumeAfter[T comparable](seq iter.Seq[T], marker T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		found := false
+		for t := range seq {
+			if !found {
+				found = t == marker
+				continue
+			}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// TakeUntil returns a sequence of the elements of the sequence before the first occurrence of marker. The marker itself
+// is not yielded. If the marker does not occur, every element is yielded. The provided sequence is iterated over lazily
+// when the returned sequence is iterated over.
+func TakeUntil[T comparable](seq iter.Seq[T], marker T) iter.Seq[T] {
+	return TakeWhile(seq, func(t T) bool {
+		return t != marker
+	})
+}
+
+// Spy returns a sequence that yields the same elements as the provided sequence, writing the bytes returned by format
+// for each element to w as it passes through. It is like [Tap] for the common case of auditing or logging a pipeline.
+// Errors writing to w are ignored; the elements are passed on regardless. The provided sequence is iterated over lazily
+// when the returned sequence is iterated over.
+func Spy[T any](seq iter.Seq[T], w io.Writer, format func(T) []byte) iter.Seq[T] {
+	return Tap(seq, func(t T) {
+		_, _ = w.Write(format(t))
+	})
+}
+
+// Iterate returns an infinite sequence that yields seed, fn(seed), fn(fn(seed)), and so on. Bound iteration with
+// something like [Take] or [TakeWhile]. Function application happens lazily when the returned sequence is iterated
+// over, and restarts from seed on each iteration.
+func Iterate[T any](seed T, fn func(T) T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for t := seed; yield(t); t = fn(t) {
+		}
+	}
+}
+
+// ForEachSeq calls fn for each inner sequence of outer, running up to parallel calls concurrently. The outer sequence
+// is iterated over on the calling goroutine; each inner sequence is read and then held until a call slot is free, so
+// at most one is read ahead of the running calls. It pairs naturally with the output of [Chunk]. If a call returns an
+// error, the context passed to the other calls is canceled, no further calls are started, and ForEachSeq returns that
+// error once the running calls have returned. If ctx is canceled first, ForEachSeq stops starting calls and returns
+// ctx.Err() once the running calls have returned; if it is canceled only after every inner sequence has been handed to
+// a call, the calls' results stand. The parallel count must be at least 1; if not, the function will panic.
+func ForEachSeq[T any](ctx context.Context, outer iter.Seq[iter.Seq[T]], parallel int, fn func(context.Context, iter.Seq[T]) error) error {
+	if parallel < 1 {
+		panic("seq: ForEachSeq parallel must be at least 1")
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	stopped := false // inner sequences were left uncalled because ctx was done
+	slots := make(chan struct{}, parallel)
+	for inner := range outer {
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			stopped = true
+			break
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if err := fn(ctx, inner); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if stopped {
+		return parent.Err()
+	}
+	return nil
+}
+
+// Unfold returns a sequence generated from an initial state. For each element fn is called with the current state and
+// returns the element to yield, the next state, and whether to yield it at all: the sequence ends the first time fn
+// returns false. Function application happens lazily when the returned sequence is iterated over, and restarts from
+// state on each iteration.
+func Unfold[S, T any](state S, fn func(S) (T, S, bool)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s := state
+		for {
+			t, next, ok := fn(s)
+			if !ok || !yield(t) {
+				return
+			}
+			s = next
+		}
+	}
+}
+
+// Generate returns a sequence that yields the values returned by calling fn repeatedly, ending the first time fn
+// returns false. Function application happens lazily when the returned sequence is iterated over.
+func Generate[T any](fn func() (T, bool)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			t, ok := fn()
+			if !ok || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// GenerateCtx is like [Generate] but passes ctx to fn and also ends when the context is canceled. The context is checked
+// before each call, so once it is canceled fn is not called again; fn should itself honor ctx to stop a call that is
+// blocked waiting for a value.
+func GenerateCtx[T any](ctx context.Context, fn func(context.Context) (T, bool)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for ctx.Err() == nil {
+			t, ok := fn(ctx)
+			if !ok || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// OnStop returns a sequence that yields the same elements as the provided sequence and calls fn if the consumer stops
+// iterating before the provided sequence is exhausted. fn receives the number of elements the consumer received,
+// including the one it stopped on, and is called at most once per iteration. It is not called when the provided
+// sequence runs out. This lets a producer release resources, log abandonment, or record metrics when its consumer gives
+// up. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func OnStop[T any](seq iter.Seq[T], fn func(consumed int)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		var consumed int
+		for t := range seq {
+			consumed++
+			if !yield(t) {
+				fn(consumed)
+				return
+			}
+		}
+	}
+}
+
+// Pairwise returns a key-value sequence of each pair of adjacent elements in the sequence: the previous element as the
+// key and the current element as the value. A sequence with fewer than two elements yields no pairs. The provided
+// sequence is iterated over lazily when the returned sequence is iterated over.
+func Pairwise[T any](seq iter.Seq[T]) iter.Seq2[T, T] {
+	return func(yield func(T, T) bool) {
+		var prev T
+		first := true
+		for t := range seq {
+			if !first && !yield(prev, t) {
+				return
+			}
+			first = false
+			prev = t
+		}
+	}
+}
+
+// WithIDs returns a key-value sequence that pairs each value in the sequence with an ID returned by gen, so individual
+// values can be traced through later stages of a pipeline. Stages like [MapValues], [FilterKV], and [TapKV] keep the ID
+// key attached to each value. gen is called once per value, lazily when the returned sequence is iterated over.
+func WithIDs[T any](seq iter.Seq[T], gen func() string) iter.Seq2[string, T] {
+	return IterKV(seq, func(T) string {
+		return gen()
+	})
+}
+
+// MapValues maps the values of the key-value pairs in the sequence to new values by applying fn to each value, leaving
+// the keys unchanged. Function application happens lazily when the returned sequence is iterated over.
+func MapValues[K, V, V1 any](seq iter.Seq2[K, V], fn func(V) V1) iter.Seq2[K, V1] {
+	return func(yield func(K, V1) bool) {
+		for k, v := range seq {
+			if !yield(k, fn(v)) {
+				return
+			}
+		}
+	}
+}
+
+// WithCursor returns a key-value sequence that pairs each value in the sequence with the resume cursor returned by
+// cursor for it. It is [IterKV] specialized for pagination; combine it with [LastCursor] to learn the cursor to resume
+// from. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func WithCursor[T any](seq iter.Seq[T], cursor func(T) string) iter.Seq2[string, T] {
+	return IterKV(seq, cursor)
+}
+
+// LastCursor returns a sequence that yields the same cursor-value pairs as the provided sequence, such as one from
+// [WithCursor], along with a function that reports the cursor of the last pair delivered to the consumer. The function's
+// second return value is false if no pair has been delivered. This lets a server streaming results emit a resume cursor
+// for the final delivered value without a second pass. The reported cursor is reset each time the returned sequence is
+// iterated over. The returned sequence and function are not safe for concurrent use. The provided sequence is iterated
+// over lazily when the returned sequence is iterated over.
+func LastCursor[T any](seq iter.Seq2[string, T]) (iter.Seq2[string, T], func() (string, bool)) {
+	var last string
+	var delivered bool
+	tracked := func(yield func(string, T) bool) {
+		last, delivered = "", false
+		for c, t := range seq {
+			last, delivered = c, true
+			if !yield(c, t) {
+				return
+			}
+		}
+	}
+	return tracked, func() (string, bool) {
+		return last, delivered
+	}
+}
+
+// SlowSubscriberPolicy decides what a [Broadcaster] does when a subscriber's buffer is full.
+type SlowSubscriberPolicy int
+
+const (
+	// BlockSlow makes the broadcaster wait for the subscriber to make room, which holds up every other subscriber.
+	BlockSlow SlowSubscriberPolicy = iota
+	// DropSlow makes the broadcaster skip the element for that subscriber only.
+	DropSlow
+)
+
+// Broadcaster consumes a single source sequence and delivers every element to each of its current subscribers, turning
+// the sequence into a small in-process event bus. Subscribers can join and leave at any time and receive the elements
+// delivered while they are subscribed. Create one with [NewBroadcaster], add subscribers with Subscribe, and start
+// delivery with Run. A Broadcaster is safe for concurrent use.
+type Broadcaster[T any] struct {
+	seq    iter.Seq[T]
+	mu     sync.Mutex
+	subs   map[*subscription[T]]struct{}
+	closed bool
+}
+
+type subscription[T any] struct {
+	ch     chan T
+	policy SlowSubscriberPolicy
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewBroadcaster returns a Broadcaster for the provided sequence. The sequence is not iterated over until Run is called.
+func NewBroadcaster[T any](seq iter.Seq[T]) *Broadcaster[T] {
+	return &Broadcaster[T]{seq: seq, subs: make(map[*subscription[T]]struct{})}
+}
+
+// Subscribe adds a subscriber that buffers up to buf elements and applies policy when its buffer is full. It returns
+// the sequence of elements delivered from now on and a function that unsubscribes. The subscription also ends when the
+// consumer stops iterating the returned sequence, or when Run returns. The returned sequence may only be iterated over
+// once. Subscribing after Run has returned yields an empty sequence. It is safe to call the unsubscribe function more
+// than once.
+func (b *Broadcaster[T]) Subscribe(buf int, policy SlowSubscriberPolicy) (iter.Seq[T], func()) {
+	s := &subscription[T]{ch: make(chan T, max(buf, 0)), policy: policy, done: make(chan struct{})}
+	b.mu.Lock()
+	if b.closed {
+		close(s.ch)
+	} else {
+		b.subs[s] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		s.once.Do(func() {
+			close(s.done)
+			b.mu.Lock()
+			delete(b.subs, s)
+			b.mu.Unlock()
+		})
+	}
+	return func(yield func(T) bool) {
+		defer unsubscribe()
+		for {
+			select {
+			case <-s.done:
+				return
+			case t, ok := <-s.ch:
+				if !ok || !yield(t) {
+					return
+				}
+			}
+		}
+	}, unsubscribe
+}
+
+// Run iterates over the source sequence, delivering each element to the current subscribers, until the sequence is
+// exhausted or ctx is canceled. When Run returns every subscription ends once its buffered elements are consumed. Run
+// returns ctx.Err() if the context was canceled and nil otherwise. Run must only be called once.
+func (b *Broadcaster[T]) Run(ctx context.Context) error {
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.closed = true
+		for s := range b.subs {
+			close(s.ch)
+			delete(b.subs, s)
+		}
+	}()
+
+	var subs []*subscription[T]
+	for t := range b.seq {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.mu.Lock()
+		subs = subs[:0]
+		for s := range b.subs {
+			subs = append(subs, s)
+		}
+		b.mu.Unlock()
+
+		for _, s := range subs {
+			if s.policy == DropSlow {
+				select {
+				case s.ch <- t:
+				default:
+				}
+				continue
+			}
+			select {
+			case s.ch <- t:
+			case <-s.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// OfType returns a sequence of the elements of the provided sequence that hold a value of type T, converted to T.
+// Elements of other types are skipped. T may be an interface type, in which case elements implementing it are kept.
+// The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func OfType[T any](seq iter.Seq[any]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if t, ok := v.(T); ok {
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AssertType returns a sequence of the elements of the provided sequence converted to T, paired with a nil error.
+// Elements that do not hold a value of type T are yielded as the zero value of T with an error describing the
+// mismatch. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func AssertType[T any](seq iter.Seq[any]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v := range seq {
+			t, ok := v.(T)
+			var err error
+			if !ok {
+				err = fmt.Errorf("seq: %T is not %v", v, reflect.TypeFor[T]())
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// Sorted returns a sequence of the elements of the provided sequence in ascending order. The whole sequence is buffered
+// and sorted before the first element is yielded, so it must be finite. The sort is stable. The provided sequence is
+// iterated over when the returned sequence is iterated over.
+func Sorted[T cmp.Ordered](seq iter.Seq[T]) iter.Seq[T] {
+	return SortedFunc(seq, cmp.Compare[T])
+}
+
+// SortedFunc returns a sequence of the elements of the provided sequence ordered by the provided comparison function.
+// The whole sequence is buffered and sorted before the first element is yielded, so it must be finite. The sort is
+// stable. The provided sequence is iterated over when the returned sequence is iterated over.
+func SortedFunc[T any](seq iter.Seq[T], compare func(T, T) int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s := slices.Collect(seq)
+		slices.SortStableFunc(s, compare)
+		for _, t := range s {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// DiffSorted returns the elements only in a and the elements only in b, given two sequences sorted in ascending order.
+// [cmp.Compare] is used to compare elements. Duplicates are matched one for one, so an element appearing twice in a and
+// once in b appears once in onlyA. Unlike a hash-based difference no set is built; each returned sequence performs a
+// single merge pass over both inputs, holding only the current element of each. If the inputs are not sorted the
+// result is unspecified. The provided sequences are iterated over lazily when a returned sequence is iterated over.
+func DiffSorted[T cmp.Ordered](a, b iter.Seq[T]) (onlyA, onlyB iter.Seq[T]) {
+	return DiffSortedFunc(a, b, cmp.Compare)
+}
+
+// DiffSortedFunc is like [DiffSorted] but uses the function to compare elements. The inputs must be sorted according
+// to the same function.
+func DiffSortedFunc[T any](a, b iter.Seq[T], compare func(T, T) int) (onlyA, onlyB iter.Seq[T]) {
+	return sortedMinus(a, b, compare), sortedMinus(b, a, compare)
+}
+
+// sortedMinus yields the elements of a that are not matched by an equal element of b, given both are sorted.
+func sortedMinus[T any](a, b iter.Seq[T], compare func(T, T) int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		next, stop := iter.Pull(b)
+		defer stop()
+		bv, bok := next()
+		for av := range a {
+			for bok && compare(bv, av) < 0 {
+				bv, bok = next()
+			}
+			if bok && compare(bv, av) == 0 {
+				bv, bok = next()
+				continue
+			}
+			if !yield(av) {
+				return
+			}
+		}
+	}
+}
+
+// SortedByKey returns a sequence of the key-value pairs of the provided sequence in ascending key order. The sort is
+// stable, so pairs with equal keys keep their relative order. The whole sequence is buffered and sorted before the
+// first pair is yielded, so it must be finite. This is useful for deterministic output from maps. The provided sequence
+// is iterated over when the returned sequence is iterated over.
+func SortedByKey[K cmp.Ordered, V any](seq iter.Seq2[K, V]) iter.Seq2[K, V] {
+	return SortedKVFunc(seq, func(a, b KV[K, V]) int { return cmp.Compare(a.K, b.K) })
+}
+
+// SortedByValue returns a sequence of the key-value pairs of the provided sequence in ascending value order. The sort
+// is stable, so pairs with equal values keep their relative order. The whole sequence is buffered and sorted before the
+// first pair is yielded, so it must be finite. The provided sequence is iterated over when the returned sequence is
+// iterated over.
+func SortedByValue[K any, V cmp.Ordered](seq iter.Seq2[K, V]) iter.Seq2[K, V] {
+	return SortedKVFunc(seq, func(a, b KV[K, V]) int { return cmp.Compare(a.V, b.V) })
+}
+
+// SortedKVFunc returns a sequence of the key-value pairs of the provided sequence ordered by the provided comparison
+// function. The sort is stable. The whole sequence is buffered and sorted before the first pair is yielded, so it must
+// be finite. The provided sequence is iterated over when the returned sequence is iterated over.
+func SortedKVFunc[K, V any](seq iter.Seq2[K, V], compare func(KV[K, V], KV[K, V]) int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var s []KV[K, V]
+		for k, v := range seq {
+			s = append(s, KV[K, V]{K: k, V: v})
+		}
+		slices.SortStableFunc(s, compare)
+		for _, kv := range s {
+			if !yield(kv.K, kv.V) {
+				return
+			}
+		}
+	}
+}
+
+// RetrySeq returns a sequence of the values of the sequence returned by open, reopening it when open or the sequence
+// fails. Values are yielded with a nil error. When an error occurs and fewer than attempts opens have been made, the
+// next delay is taken from backoff and slept (a nil or exhausted backoff means no delay), resume is called with the
+// number of values delivered so far, and open is called again. Open is expected to use the count passed to resume to
+// continue after the values already delivered. If resume is nil, RetrySeq instead skips that many values of the reopened
+// sequence itself. Once attempts opens have failed, the last error is yielded with the zero value of T and the sequence
+// ends. The attempts limit covers the lifetime of the returned sequence and is not reset by progress. RetrySeq panics
+// if attempts is less than 1. The sources are opened lazily when the returned sequence is iterated over.
+func RetrySeq[T any](open func() (iter.Seq2[T, error], error), attempts int, backoff iter.Seq[time.Duration], resume func(consumed int)) iter.Seq2[T, error] {
+	if attempts < 1 {
+		panic("seq: RetrySeq attempts must be at least 1")
+	}
+	return func(yield func(T, error) bool) {
+		nextDelay := func() (time.Duration, bool) { return 0, false }
+		if backoff != nil {
+			next, stop := iter.Pull(backoff)
+			defer stop()
+			nextDelay = next
+		}
+
+		var consumed int
+		for attempt := 1; ; attempt++ {
+			if attempt > 1 {
+				if d, ok := nextDelay(); ok && d > 0 {
+					time.Sleep(d)
+				}
+				if resume != nil {
+					resume(consumed)
+				}
+			}
+
+			s, err := open()
+			if err == nil {
+				var skip int
+				if resume == nil {
+					skip = consumed
+				}
+				for t, e := range s {
+					if e != nil {
+						err = e
+						break
+					}
+					if skip > 0 {
+						skip--
+						continue
+					}
+					consumed++
+					if !yield(t, nil) {
+						return
+					}
+				}
+				if err == nil {
+					return
+				}
+			}
+
+			if attempt >= attempts {
+				var zero T
+				yield(zero, err)
+				return
+			}
+		}
+	}
+}
+
+// TopN returns the n greatest elements of the sequence, greatest first, using [cmp.Compare]. If the sequence has fewer
+// than n elements all of them are returned. If n is not positive, nil is returned. At most n elements are held at a
+// time, so the k largest values of a huge sequence can be found without sorting or collecting it.
+func TopN[T cmp.Ordered](seq iter.Seq[T], n int) []T {
+	return TopNFunc(seq, n, cmp.Compare[T])
+}
+
+// TopNFunc is like [TopN] but uses the function to compare elements. Of elements that compare equal, the ones seen
+// first are kept, and they are returned in the order they were seen.
+func TopNFunc[T any](seq iter.Seq[T], n int, compare func(T, T) int) []T {
+	return topN(seq, n, compare)
+}
+
+// TopNFuncKV is like [TopNFunc] but for key-value sequences.
+func TopNFuncKV[K, V any](seq iter.Seq2[K, V], n int, compare func(KV[K, V], KV[K, V]) int) []KV[K, V] {
+	kvs := func(yield func(KV[K, V]) bool) {
+		for k, v := range seq {
+			if !yield(KV[K, V]{K: k, V: v}) {
+				return
+			}
+		}
+	}
+	return topN(kvs, n, compare)
+}
+
+// topN implements TopNFunc and TopNFuncKV. Each element is tagged with its position so that, of elements that compare
+// equal, the later one ranks lower: it is the one evicted from the heap, and it sorts after the earlier ones.
+func topN[T any](seq iter.Seq[T], n int, compare func(T, T) int) []T {
+	if n <= 0 {
+		return nil
+	}
+	type ranked struct {
+		t T
+		i int
+	}
+	h := topHeap[ranked]{n: n, compare: func(a, b ranked) int {
+		if c := compare(a.t, b.t); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.i, a.i)
+	}}
+	i := 0
+	for t := range seq {
+		h.offer(ranked{t: t, i: i})
+		i++
+	}
+	out := make([]T, len(h.s))
+	for j, r := range h.sorted() {
+		out[j] = r.t
+	}
+	return out
+}
+
+// BottomN returns the n smallest elements of the sequence, smallest first, using [cmp.Compare]. If the sequence has
+// fewer than n elements all of them are returned. If n is not positive, nil is returned. At most n elements are held at
+// a time.
+func BottomN[T cmp.Ordered](seq iter.Seq[T], n int) []T {
+	return BottomNFunc(seq, n, cmp.Compare[T])
+}
+
+// BottomNFunc is like [BottomN] but uses the function to compare elements. Of elements that compare equal, the ones
+// seen first are kept.
+func BottomNFunc[T any](seq iter.Seq[T], n int, compare func(T, T) int) []T {
+	return TopNFunc(seq, n, func(a, b T) int { return compare(b, a) })
+}
+
+// BottomNFuncKV is like [BottomNFunc] but for key-value sequences.
+func BottomNFuncKV[K, V any](seq iter.Seq2[K, V], n int, compare func(KV[K, V], KV[K, V]) int) []KV[K, V] {
+	return TopNFuncKV(seq, n, func(a, b KV[K, V]) int { return compare(b, a) })
+}
+
+// topHeap is a min-heap ordered by compare. Through offer it holds the n greatest elements offered to it: the smallest
+// kept element is at the root and can be replaced in O(log n) when a greater one arrives. It can also be filled
+// directly, made a heap with heapify, and drained smallest first with pop.
+type topHeap[T any] struct {
+	s       []T
+	n       int
+	compare func(T, T) int
+}
+
+// offer adds t if fewer than n elements are held or t is greater than the smallest of them, which it then replaces.
+func (h *topHeap[T]) offer(t T) {
+	if len(h.s) < h.n {
+		h.s = append(h.s, t)
+		h.up(len(h.s) - 1)
+		return
+	}
+	if h.compare(t, h.s[0]) <= 0 {
+		return
+	}
+	h.s[0] = t
+	h.down(0)
+}
+
+func (h *topHeap[T]) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if h.compare(h.s[i], h.s[p]) >= 0 {
+			return
+		}
+		h.s[i], h.s[p] = h.s[p], h.s[i]
+		i = p
+	}
+}
+
+func (h *topHeap[T]) down(i int) {
+	for {
+		least := i
+		for _, c := range [2]int{2*i + 1, 2*i + 2} {
+			if c < len(h.s) && h.compare(h.s[c], h.s[least]) < 0 {
+				least = c
+			}
+		}
+		if least == i {
+			return
+		}
+		h.s[i], h.s[least] = h.s[least], h.s[i]
+		i = least
+	}
+}
+
+// sorted returns the held elements greatest first. The heap must not be used afterwards.
+func (h *topHeap[T]) sorted() []T {
+	slices.SortFunc(h.s, func(a, b T) int { return h.compare(b, a) })
+	return h.s
+}
+
+// FairMergeKV merges key-value sequences whose keys identify tenants, so that no tenant can starve the others. Merging
+// proceeds in rounds; in each round a key may be yielded at most as many times as its quota, and sweeps take the next
+// pair of each sequence in turn. A sequence whose next pair belongs to a key that has used up its quota is held back
+// until the next round, keeping the order of each sequence and buffering at most one pair per sequence. Keys missing
+// from quotas, or with a quota less than 1, have a quota of 1. The returned sequence ends when every provided sequence
+// is exhausted or ctx is canceled; cancellation is checked before each pair is pulled, so a sequence blocked producing a
+// pair delays it. The provided sequences are iterated over lazily when the returned sequence is iterated over.
+func FairMergeKV[K comparable, V any](ctx context.Context, quotas map[K]int, seqs ...iter.Seq2[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		type source struct {
+			next func() (K, V, bool)
+			head KV[K, V]
+			held bool
+			done bool
+		}
+		sources := make([]source, len(seqs))
+		for i, s := range seqs {
+			next, stop := iter.Pull2(s)
+			defer stop()
+			sources[i].next = next
+		}
+		quota := func(k K) int { return max(quotas[k], 1) }
+
+		used := make(map[K]int)
+		for {
+			clear(used)
+			progress, live := true, false
+			for progress {
+				progress, live = false, false
+				for i := range sources {
+					src := &sources[i]
+					if src.done {
+						continue
+					}
+					if !src.held {
+						if ctx.Err() != nil {
+							return
+						}
+						k, v, ok := src.next()
+						if !ok {
+							src.done = true
+							continue
+						}
+						src.head, src.held = KV[K, V]{K: k, V: v}, true
+					}
+					live = true
+					if k := src.head.K; used[k] < quota(k) {
+						used[k]++
+						src.held = false
+						progress = true
+						if !yield(k, src.head.V) {
+							return
+						}
+					}
+				}
+			}
+			if !live {
+				return
+			}
+		}
+	}
+}
+
+// UniqueBy returns a sequence that yields the first element for each distinct key, as returned by the key function.
+// Unlike [Unique] the elements need not be comparable, so structs can be de-duplicated on an ID field. It needs memory
+// proportional to the number of distinct keys. The provided sequence is iterated over lazily when the returned sequence
+// is iterated over.
+func UniqueBy[T any, K comparable](seq iter.Seq[T], key func(T) K) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		seen := make(map[K]struct{})
+		for t := range seq {
+			k := key(t)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// GzipSeq returns a sequence of the gzip compressed form of the concatenated chunks of the provided sequence, using the
+// given compression level (see [compress/gzip] for valid levels). Output chunks are yielded as the compressor produces
+// them, so their boundaries do not match the input chunks; concatenated they form a single gzip stream. Compression
+// errors, including an invalid level, are yielded with a nil chunk and end the sequence. The provided sequence is
+// iterated over lazily when the returned sequence is iterated over.
+func GzipSeq(seq iter.Seq[[]byte], level int) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		flush := func() bool {
+			if buf.Len() == 0 {
+				return true
+			}
+			out := bytes.Clone(buf.Bytes())
+			buf.Reset()
+			return yield(out, nil)
+		}
+		for chunk := range seq {
+			if _, err := w.Write(chunk); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !flush() {
+				return
+			}
+		}
+		if err := w.Close(); err != nil {
+			yield(nil, err)
+			return
+		}
+		flush()
+	}
+}
+
+// GunzipSeq returns a sequence of the decompressed contents of the gzip stream formed by concatenating the chunks of
+// the provided sequence. Concatenated gzip members are decompressed as one stream, as with [gzip.Reader]. Output chunks
+// are at most 32 KiB and their boundaries do not match the input chunks. Decompression errors, including a truncated or
+// corrupt stream, are yielded with a nil chunk and end the sequence; an input with no bytes at all is not an error but
+// an empty sequence. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func GunzipSeq(seq iter.Seq[[]byte]) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		next, stop := iter.Pull(seq)
+		defer stop()
+		r, err := gzip.NewReader(&chunkReader{next: next})
+		if err == io.EOF {
+			return // no input at all
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			b := make([]byte, 32<<10)
+			n, err := r.Read(b)
+			if n > 0 && !yield(b[:n], nil) {
+				return
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// chunkReader is an [io.Reader] over the chunks returned by next.
+type chunkReader struct {
+	next func() ([]byte, bool)
+	buf  []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		b, ok := r.next()
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = b
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// UniqueByKey returns a sequence that yields the first key-value pair for each distinct key; later pairs with a key
+// already seen are dropped whatever their value. Unlike [UniqueKV] the values need not be comparable. It needs memory
+// proportional to the number of distinct keys. The provided sequence is iterated over lazily when the returned sequence
+// is iterated over.
+func UniqueByKey[K comparable, V any](seq iter.Seq2[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		seen := make(map[K]struct{})
+		for k, v := range seq {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// SampleStable returns a sequence of the elements of the provided sequence whose key, as returned by the key function,
+// hashes below the given fraction of the hash space. The hash is 64-bit FNV-1a with its bits mixed so that similar keys
+// spread evenly; it is fixed, so the same keys are sampled in every run, process, and service, unlike random sampling.
+// A fraction of 0 or less keeps nothing and a fraction of 1 or more keeps everything. The provided sequence is iterated
+// over lazily when the returned sequence is iterated over.
+func SampleStable[T any](seq iter.Seq[T], fraction float64, key func(T) string) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		h := fnv.New64a()
+		for t := range seq {
+			h.Reset()
+			h.Write([]byte(key(t)))
+			// float64 rounds the largest hashes up to 1, so a fraction of 1 must be special cased to keep everything
+			if (fraction >= 1 || float64(mix64(h.Sum64()))/(1<<64) < fraction) && !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// mix64 is the MurmurHash3 64-bit finalizer. It spreads every input bit over every output bit, which FNV alone does not
+// do for the high bits of short keys.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Option holds either a value of type T or no value. It lets sequences represent "no value" for an element explicitly
+// instead of with a zero value, which functions like [Coalesce] can't tell apart from a real one. The zero Option holds
+// no value. Create one with [Some] or [NoValue].
+type Option[T any] struct {
+	v  T
+	ok bool
+}
+
+// Some returns an Option holding v.
+func Some[T any](v T) Option[T] {
+	return Option[T]{v: v, ok: true}
+}
+
+// NoValue returns an Option holding no value. It is not named None because [None] is the negated predicate check.
+func NoValue[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// Get returns the value held by the Option and true, or the zero value of T and false if it holds no value.
+func (o Option[T]) Get() (T, bool) {
+	return o.v, o.ok
+}
+
+// IsSome returns true if the Option holds a value.
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+// SomeSeq returns a sequence of the elements of the provided sequence, each held by an Option. The provided sequence is
+// iterated over lazily when the returned sequence is iterated over.
+func SomeSeq[T any](seq iter.Seq[T]) iter.Seq[Option[T]] {
+	return Map(seq, Some[T])
+}
+
+// FlattenOption returns a sequence of the values held by the Options of the provided sequence, skipping those that hold
+// no value. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func FlattenOption[T any](seq iter.Seq[Option[T]]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for o := range seq {
+			if o.ok && !yield(o.v) {
+				return
+			}
+		}
+	}
+}
+
+// MapOption returns a sequence of the values held by the Options returned by applying fn to each element of the
+// provided sequence, skipping elements for which fn returns no value. It maps and filters in one step. The provided
+// sequence is iterated over lazily when the returned sequence is iterated over.
+func MapOption[T, O any](seq iter.Seq[T], fn func(T) Option[O]) iter.Seq[O] {
+	return FlattenOption(Map(seq, fn))
+}
+
+// Index is a key-value sequence materialized into a map for repeated lookups, while still being usable as a sequence.
+// Create one with [NewIndex]. An Index is not modified after it is built, so it is safe for concurrent use.
+type Index[K comparable, V any] struct {
+	m    map[K]V
+	keys []K
+}
+
+// NewIndex iterates over the provided sequence and returns an Index of its key-value pairs. If a key occurs more than
+// once the last value wins, as with [maps.Collect], but the key keeps the position where it was first seen.
+func NewIndex[K comparable, V any](seq iter.Seq2[K, V]) *Index[K, V] {
+	idx := &Index[K, V]{m: make(map[K]V)}
+	for k, v := range seq {
+		if _, ok := idx.m[k]; !ok {
+			idx.keys = append(idx.keys, k)
+		}
+		idx.m[k] = v
+	}
+	return idx
+}
+
+// Get returns the value for the key and true, or the zero value and false if the key is not in the Index.
+func (idx *Index[K, V]) Get(k K) (V, bool) {
+	v, ok := idx.m[k]
+	return v, ok
+}
+
+// Len returns the number of keys in the Index.
+func (idx *Index[K, V]) Len() int {
+	return len(idx.keys)
+}
+
+// Keys returns a sequence of the keys in the Index in first-seen order.
+func (idx *Index[K, V]) Keys() iter.Seq[K] {
+	return slices.Values(idx.keys)
+}
+
+// Seq returns a sequence of the key-value pairs in the Index in first-seen key order.
+func (idx *Index[K, V]) Seq() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, k := range idx.keys {
+			if !yield(k, idx.m[k]) {
+				return
+			}
+		}
+	}
+}
+
+// Duplicates returns a key-value sequence of the values that occur more than once in the provided sequence, each
+// paired with the number of times it occurs. Values are yielded in the order of their first repeat. The provided
+// sequence is iterated over completely when the returned sequence is iterated over, needing memory proportional to the
+// number of distinct values.
+func Duplicates[T comparable](seq iter.Seq[T]) iter.Seq2[T, int] {
+	return func(yield func(T, int) bool) {
+		counts := make(map[T]int)
+		var order []T
+		for t := range seq {
+			if counts[t] == 1 {
+				order = append(order, t)
+			}
+			counts[t]++
+		}
+		for _, t := range order {
+			if !yield(t, counts[t]) {
+				return
+			}
+		}
+	}
+}
+
+// HasDuplicates returns true if any value occurs more than once in the sequence. The sequence is iterated over until
+// the first repeated value when HasDuplicates is called.
+func HasDuplicates[T comparable](seq iter.Seq[T]) bool {
+	seen := make(map[T]struct{})
+	for t := range seq {
+		if _, ok := seen[t]; ok {
+			return true
+		}
+		seen[t] = struct{}{}
+	}
+	return false
+}
+
+// GroupAdjacent returns a key-value sequence of the runs of adjacent elements with equal keys, as returned by the key
+// function, each paired with a sequence of the run's elements. Unlike [GroupBy], which materializes the whole input,
+// each group is yielded as soon as the key changes, so only one group is held at a time; on input ordered by key this
+// groups the whole sequence. A key that reappears later starts a new group. The provided sequence is iterated over
+// lazily when the returned sequence is iterated over.
+func GroupAdjacent[T any, K comparable](seq iter.Seq[T], key func(T) K) iter.Seq2[K, iter.Seq[T]] {
+	return func(yield func(K, iter.Seq[T]) bool) {
+		var (
+			cur   K
+			group []T
+		)
+		for t := range seq {
+			k := key(t)
+			if len(group) > 0 && k != cur {
+				if !yield(cur, slices.Values(group)) {
+					return
+				}
+				group = nil
+			}
+			cur = k
+			group = append(group, t)
+		}
+		if len(group) > 0 {
+			yield(cur, slices.Values(group))
+		}
+	}
+}
+
+// WriteOption configures [WritePartitioned].
+type WriteOption func(*writeConfig)
+
+type writeConfig struct {
+	maxFileSize int64
+	suffix      string
+}
+
+// MaxFileSize makes [WritePartitioned] start a new file for a partition once writing the next element would take the
+// current file over n bytes. A single element larger than n still gets a file of its own. By default files are not
+// rotated.
+func MaxFileSize(n int64) WriteOption {
+	return func(c *writeConfig) { c.maxFileSize = n }
+}
+
+// FileSuffix sets the suffix, such as ".jsonl", of the files written by [WritePartitioned]. By default there is none.
+func FileSuffix(suffix string) WriteOption {
+	return func(c *writeConfig) { c.suffix = suffix }
+}
+
+// WritePartitioned writes the elements of the provided sequence into files under dir, grouped by the partition
+// returned for each element, such as a date or tenant. Each element is converted to bytes with encode and written as is,
+// so encode must add any separator, like a trailing newline. The files of a partition are dir/<partition>/part-00000,
+// part-00001, and so on, plus any [FileSuffix]; a new file is started when [MaxFileSize] would be exceeded. Directories
+// are created as needed and existing files are truncated. A partition must be a local path as defined by
+// [filepath.IsLocal]. Every partition keeps a file open until the sequence is exhausted, so the number of partitions is
+// bounded by the open file limit. WritePartitioned returns the number of elements written to each partition; on error
+// the counts cover the elements written before it. The provided sequence is iterated over when WritePartitioned is
+// called.
+func WritePartitioned[T any](seq iter.Seq[T], dir string, partition func(T) string, encode func(T) []byte, opts ...WriteOption) (map[string]int, error) {
+	var cfg writeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	type partFile struct {
+		f    *os.File
+		w    *bufio.Writer
+		n    int
+		size int64
+	}
+	counts := make(map[string]int)
+	files := make(map[string]*partFile)
+	closeFile := func(pf *partFile) error {
+		return cmp.Or(pf.w.Flush(), pf.f.Close())
+	}
+	open := func(p string, n int) (*partFile, error) {
+		pdir := filepath.Join(dir, p)
+		if err := os.MkdirAll(pdir, 0o755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(filepath.Join(pdir, fmt.Sprintf("part-%05d%s", n, cfg.suffix)))
+		if err != nil {
+			return nil, err
+		}
+		return &partFile{f: f, w: bufio.NewWriter(f), n: n}, nil
+	}
+
+	write := func(t T) error {
+		p := partition(t)
+		if !filepath.IsLocal(p) {
+			return fmt.Errorf("seq: partition %q is not a local path", p)
+		}
+		data := encode(t)
+		pf, ok := files[p]
+		if ok && cfg.maxFileSize > 0 && pf.size > 0 && pf.size+int64(len(data)) > cfg.maxFileSize {
+			if err := closeFile(pf); err != nil {
+				delete(files, p)
+				return err
+			}
+			ok = false
+		}
+		if !ok {
+			next := 0
+			if pf != nil {
+				next = pf.n + 1
+			}
+			var err error
+			if pf, err = open(p, next); err != nil {
+				delete(files, p)
+				return err
+			}
+			files[p] = pf
+		}
+		if _, err := pf.w.Write(data); err != nil {
+			return err
+		}
+		pf.size += int64(len(data))
+		counts[p]++
+		return nil
+	}
+
+	var err error
+	for t := range seq {
+		if err = write(t); err != nil {
+			break
+		}
+	}
+	for _, pf := range files {
+		err = errors.Join(err, closeFile(pf))
+	}
+	return counts, err
+}
+
+// Watchdog returns a sequence of the elements of the provided sequence that calls onStall when the provided sequence
+// takes longer than maxGap to produce its next element. Only time spent waiting for the provided sequence counts; time
+// spent by the consumer handling an element does not. While the stall lasts onStall is called again every maxGap, with
+// how long the wait has lasted so far and the number of elements received before it. onStall runs on its own
+// goroutine, concurrently with the iteration, and may for example log or cancel a context that the provided sequence
+// observes. The duration maxGap must be greater than zero; if not, the function will panic. The provided sequence is
+// iterated over lazily when the returned sequence is iterated over.
+func Watchdog[T any](seq iter.Seq[T], maxGap time.Duration, onStall func(stalledFor time.Duration, consumed int)) iter.Seq[T] {
+	if maxGap <= 0 {
+		panic("seq: Watchdog gap must be positive")
+	}
+	return func(yield func(T) bool) {
+		var (
+			mu           sync.Mutex
+			waitingSince time.Time
+			consumed     int
+			timer        *time.Timer
+		)
+		timer = time.AfterFunc(maxGap, func() {
+			mu.Lock()
+			if waitingSince.IsZero() || time.Since(waitingSince) < maxGap {
+				// not waiting, or a stale firing from an earlier wait; the current wait has its own timer
+				mu.Unlock()
+				return
+			}
+			stalledFor, n := time.Since(waitingSince), consumed
+			timer.Reset(maxGap)
+			mu.Unlock()
+			onStall(stalledFor, n)
+		})
+		mu.Lock()
+		waitingSince = time.Now()
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			waitingSince = time.Time{}
+			timer.Stop()
+			mu.Unlock()
+		}()
+
+		for t := range seq {
+			mu.Lock()
+			waitingSince = time.Time{}
+			consumed++
+			timer.Stop()
+			mu.Unlock()
+			if !yield(t) {
+				return
+			}
+			mu.Lock()
+			waitingSince = time.Now()
+			timer.Reset(maxGap)
+			mu.Unlock()
+		}
+	}
+}
+
+// Median returns the median of the values in the sequence: the middle value, or the mean of the two middle values if
+// there is an even number of them. It is [Percentile] with p of 50. If the sequence is empty, the second return value
+// is false. The values are buffered and sorted, so the sequence must be finite. The sequence is iterated over before
+// Median returns.
+func Median[T Number](seq iter.Seq[T]) (float64, bool) {
+	return Percentile(seq, 50)
+}
+
+// Percentile returns the p-th percentile of the values in the sequence, interpolating linearly between the two nearest
+// ranks. A p of 0 gives the minimum and a p of 100 the maximum. If the sequence is empty, the second return value is
+// false. The values are buffered and sorted, so the sequence must be finite. The sequence is iterated over before
+// Percentile returns. The p must be between 0 and 100 inclusive; if not, the function will panic.
+func Percentile[T Number](seq iter.Seq[T], p float64) (float64, bool) {
+	if p < 0 || p > 100 || p != p {
+		panic("seq: Percentile p must be between 0 and 100")
+	}
+	s := slices.Sorted(seq)
+	if len(s) == 0 {
+		return 0, false
+	}
+	rank := p / 100 * float64(len(s)-1)
+	i := int(rank)
+	if i == len(s)-1 {
+		return float64(s[i]), true
+	}
+	lo, hi := float64(s[i]), float64(s[i+1])
+	return lo + (hi-lo)*(rank-float64(i)), true
+}
+
+// ChangeKind is the kind of a [Change].
+type ChangeKind int
+
+const (
+	// ChangeAdd means the key was added, with the value New.
+	ChangeAdd ChangeKind = iota + 1
+	// ChangeUpdate means the value of the key changed from Old to New.
+	ChangeUpdate
+	// ChangeDelete means the key, which had the value Old, was removed.
+	ChangeDelete
+)
+
+// String returns the name of the kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdd:
+		return "add"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Change describes a change to the value of a key, as produced by [DiffMaps] and consumed by [ApplyKV]. Old is the zero
+// value for ChangeAdd and New is the zero value for ChangeDelete.
+type Change[V any] struct {
+	Kind     ChangeKind
+	Old, New V
+}
+
+// DiffMaps returns a key-value sequence of the changes that turn the from map into the to map: a [ChangeAdd] for keys
+// only in to, a [ChangeUpdate] for keys whose value differs, and a [ChangeDelete] for keys only in from. The changes are
+// unordered. The maps are read when the returned sequence is iterated over.
+func DiffMaps[K, V comparable](from, to map[K]V) iter.Seq2[K, Change[V]] {
+	return DiffMapsFunc(from, to, func(a, b V) bool { return a == b })
+}
+
+// DiffMapsFunc is like [DiffMaps] but uses the function to decide if two values are equal, so the values need not be
+// comparable.
+func DiffMapsFunc[K comparable, V any](from, to map[K]V, equal func(V, V) bool) iter.Seq2[K, Change[V]] {
+	return func(yield func(K, Change[V]) bool) {
+		for k, o := range from {
+			n, ok := to[k]
+			switch {
+			case !ok:
+				if !yield(k, Change[V]{Kind: ChangeDelete, Old: o}) {
+					return
+				}
+			case !equal(o, n):
+				if !yield(k, Change[V]{Kind: ChangeUpdate, Old: o, New: n}) {
+					return
+				}
+			}
+		}
+		for k, n := range to {
+			if _, ok := from[k]; !ok {
+				if !yield(k, Change[V]{Kind: ChangeAdd, New: n}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ApplyKV applies the changes to dst: adds and updates set the key to New and deletes remove the key. Old is not
+// checked against dst. Changes of any other kind are ignored. The sequence is iterated over before ApplyKV returns.
+func ApplyKV[K comparable, V any](dst map[K]V, changes iter.Seq2[K, Change[V]]) {
+	for k, c := range changes {
+		switch c.Kind {
+		case ChangeAdd, ChangeUpdate:
+			dst[k] = c.New
+		case ChangeDelete:
+			delete(dst, k)
+		}
+	}
+}
+
+// Variance returns the population variance of the values in the sequence, computed in a single pass with Welford's
+// algorithm so the values aren't buffered. If the sequence is empty, the second return value is false. The sequence is
+// iterated over before Variance returns.
+func Variance[T Number](seq iter.Seq[T]) (float64, bool) {
+	var w welford
+	for t := range seq {
+		w.add(float64(t))
+	}
+	return w.variance(), w.n > 0
+}
+
+// StdDev returns the population standard deviation of the values in the sequence, the square root of [Variance]. If the
+// sequence is empty, the second return value is false. The sequence is iterated over before StdDev returns.
+func StdDev[T Number](seq iter.Seq[T]) (float64, bool) {
+	v, ok := Variance(seq)
+	return math.Sqrt(v), ok
+}
+
+// welford accumulates the count, mean, and sum of squared deviations of values one at a time.
+type welford struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func (w *welford) add(x float64) {
+	w.n++
+	d := x - w.mean
+	w.mean += d / float64(w.n)
+	w.m2 += d * (x - w.mean)
+}
+
+// variance returns the population variance, or 0 if no values were added.
+func (w *welford) variance() float64 {
+	if w.n == 0 {
+		return 0
+	}
+	return w.m2 / float64(w.n)
+}
+
+// Fingerprint returns the number of elements in the sequence and an order-sensitive 64-bit hash of them, using encode
+// to turn each element into bytes. Two sequences with equal fingerprints almost certainly held the same elements in the
+// same order, so two sides of a transfer can check that they streamed identical data without retaining it. The hash
+// is 64-bit FNV-1a over the length-prefixed encodings, so it is stable across processes and releases; it is not a
+// cryptographic hash. The sequence is iterated over before Fingerprint returns.
+func Fingerprint[T any](seq iter.Seq[T], encode func(T) []byte) (count int, sum uint64) {
+	h := fnv.New64a()
+	var prefix [binary.MaxVarintLen64]byte
+	for t := range seq {
+		data := encode(t)
+		h.Write(binary.AppendUvarint(prefix[:0], uint64(len(data))))
+		h.Write(data)
+		count++
+	}
+	return count, h.Sum64()
+}
+
+// FingerprintUnordered is like [Fingerprint] but the hash ignores the order of the elements, so sequences holding the
+// same elements, with the same number of repeats, in any order have equal fingerprints. It is useful when the two sides
+// of a transfer may process elements concurrently or in a different order. The sequence is iterated over before
+// FingerprintUnordered returns.
+func FingerprintUnordered[T any](seq iter.Seq[T], encode func(T) []byte) (count int, sum uint64) {
+	h := fnv.New64a()
+	for t := range seq {
+		h.Reset()
+		h.Write(encode(t))
+		sum += mix64(h.Sum64())
+		count++
+	}
+	return count, sum
+}
+
+// Summary holds statistics of a numeric sequence, as returned by [Summarize]. For an empty sequence every field is
+// zero.
+type Summary[T Number] struct {
+	Count    int
+	Sum      T
+	Min, Max T
+	Mean     float64
+	// StdDev is the population standard deviation.
+	StdDev float64
+}
+
+// Summarize returns the count, sum, minimum, maximum, mean, and standard deviation of the values in the sequence,
+// computed together in a single pass without buffering the values. The sequence is iterated over before Summarize
+// returns.
+func Summarize[T Number](seq iter.Seq[T]) Summary[T] {
+	var s Summary[T]
+	var w welford
+	for t := range seq {
+		if w.n == 0 || t < s.Min {
+			s.Min = t
+		}
+		if w.n == 0 || t > s.Max {
+			s.Max = t
+		}
+		s.Sum += t
+		w.add(float64(t))
+	}
+	s.Count, s.Mean, s.StdDev = w.n, w.mean, math.Sqrt(w.variance())
+	return s
+}
+
+// Columns returns a sequence of maps from column name to value, one for each row of the provided sequence of rows,
+// such as the records read by [encoding/csv.Reader]. The column names are given by header; if header is nil the first
+// row is used as the header instead. A row with a different number of fields than the header is yielded as a nil map
+// with an error, and iteration continues with the next row. Row numbers in errors count data rows from 1. The provided
+// sequence is iterated over lazily when the returned sequence is iterated over.
+func Columns(seq iter.Seq[[]string], header []string) iter.Seq2[map[string]string, error] {
+	return func(yield func(map[string]string, error) bool) {
+		for r, err := range headedRows(seq, header) {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			m := make(map[string]string, len(r.K))
+			for i, name := range r.K {
+				m[name] = r.V[i]
+			}
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// headedRows yields each data row of seq, as V, paired with the header naming its fields, as K, for [Columns] and
+// [Bind]. If header is nil the first row is the header. A row with a different number of fields than the header is
+// yielded with an error instead.
+func headedRows(seq iter.Seq[[]string], header []string) iter.Seq2[KV[[]string, []string], error] {
+	return func(yield func(KV[[]string, []string], error) bool) {
+		h, haveHeader := header, header != nil
+		n := 0
+		for row := range seq {
+			if !haveHeader {
+				h, haveHeader = slices.Clone(row), true
+				continue
+			}
+			n++
+			if len(row) != len(h) {
+				if !yield(KV[[]string, []string]{}, fmt.Errorf("seq: row %d has %d fields, want %d", n, len(row), len(h))) {
+					return
+				}
+				continue
+			}
+			if !yield(KV[[]string, []string]{K: h, V: row}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Bind returns a sequence of values of the struct type T, one for each row of the provided sequence of rows, with the
+// columns, named as for [Columns], stored in the matching exported fields. A field matches the column named by its
+// `csv` struct tag, or else the column with the field's name, compared case-insensitively; a tag of "-" skips the
+// field. If several columns match a field, the one named exactly as the tag or field is used, or else the first
+// matching column in header order. Columns without a matching field are ignored. Values are converted to the field's
+// type: strings, bools, integers, floats, [time.Duration] via [time.ParseDuration], and any type whose pointer
+// implements [encoding.TextUnmarshaler], such as [time.Time], are supported. An empty value leaves the field at its
+// zero value. A row that can't be bound is yielded as the zero value of T with an error, for the first field in struct
+// order that fails, and iteration continues with the next row. The provided sequence is iterated over lazily when the
+// returned sequence is iterated over.
+func Bind[T any](seq iter.Seq[[]string], header []string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rt := reflect.TypeFor[T]()
+		if rt.Kind() != reflect.Struct {
+			yield(zero, fmt.Errorf("seq: Bind needs a struct type, not %v", rt))
+			return
+		}
+		var fields []boundField // built from the header once the first row arrives
+		n := 0
+		for r, err := range headedRows(seq, header) {
+			n++
+			if err != nil {
+				if !yield(zero, err) {
+					return
+				}
+				continue
+			}
+			if fields == nil {
+				fields = bindFields(rt, r.K)
+			}
+			var t T
+			v := reflect.ValueOf(&t).Elem()
+			for _, f := range fields {
+				if err = setField(v.Field(f.field), r.V[f.column]); err != nil {
+					err = fmt.Errorf("seq: row %d column %q: %w", n, r.K[f.column], err)
+					break
+				}
+			}
+			if err != nil {
+				t = zero
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// boundField pairs the index of a struct field with the index of the column stored in it.
+type boundField struct {
+	field, column int
+}
+
+// bindFields returns the fields of the struct type rt that have a matching column, in struct order. A tag must match
+// a column exactly; a field name prefers an exact match and otherwise takes the first column equal to it ignoring case.
+func bindFields(rt reflect.Type, columns []string) []boundField {
+	fields := make([]boundField, 0, rt.NumField())
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("csv"), ",")
+		if name == "-" {
+			continue
+		}
+		var col int
+		if name != "" {
+			col = slices.Index(columns, name)
+		} else if col = slices.Index(columns, f.Name); col < 0 {
+			col = slices.IndexFunc(columns, func(c string) bool { return strings.EqualFold(c, f.Name) })
+		}
+		if col >= 0 {
+			fields = append(fields, boundField{field: i, column: col})
+		}
+	}
+	return fields
+}
+
+// setField parses s into v according to v's type.
+func setField(v reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %v", v.Type())
+	}
+	return nil
+}
+
+// Join concatenates the strings of the sequence, placing sep between them, like [strings.Join] but without collecting
+// the sequence first. The sequence is iterated over before Join returns.
+func Join(seq iter.Seq[string], sep string) string {
+	return JoinFunc(seq, sep, func(s string) string { return s })
+}
+
+// JoinFunc is like [Join] but for elements of any type, using format to turn each element into a string; for example,
+// [fmt.Sprint] or a String method expression. The sequence is iterated over before JoinFunc returns.
+func JoinFunc[T any](seq iter.Seq[T], sep string, format func(T) string) string {
+	var b strings.Builder
+	first := true
+	for t := range seq {
+		if !first {
+			b.WriteString(sep)
+		}
+		first = false
+		b.WriteString(format(t))
+	}
+	return b.String()
+}
+
+// GroupBy2 returns a two-level grouping of the values: the outer sequence pairs each key returned by k1 with an inner
+// sequence pairing each key returned by k2, among the values with that first key, with a sequence of those values in
+// encounter order. Keys at both levels are yielded in first-seen order. Both levels are built in a single pass, so
+// reports shaped like region, then product, then rows need not re-iterate or chain groupings. The provided sequence is
+// iterated over completely when the returned sequence is iterated over.
+func GroupBy2[T any, K1, K2 comparable](seq iter.Seq[T], k1 func(T) K1, k2 func(T) K2) iter.Seq2[K1, iter.Seq2[K2, iter.Seq[T]]] {
+	type inner struct {
+		groups map[K2][]T
+		order  []K2
+	}
+	return func(yield func(K1, iter.Seq2[K2, iter.Seq[T]]) bool) {
+		outer := make(map[K1]*inner)
+		var order []K1
+		for t := range seq {
+			a, b := k1(t), k2(t)
+			in, ok := outer[a]
+			if !ok {
+				in = &inner{groups: make(map[K2][]T)}
+				outer[a] = in
+				order = append(order, a)
+			}
+			if _, ok := in.groups[b]; !ok {
+				in.order = append(in.order, b)
+			}
+			in.groups[b] = append(in.groups[b], t)
+		}
+		for _, a := range order {
+			in := outer[a]
+			groups := func(yield func(K2, iter.Seq[T]) bool) {
+				for _, b := range in.order {
+					if !yield(b, slices.Values(in.groups[b])) {
+						return
+					}
+				}
+			}
+			if !yield(a, groups) {
+				return
+			}
+		}
+	}
+}
+
+// ErrInvariant is the error wrapped by the errors of [AssertErr] for elements violating the invariant.
+var ErrInvariant = errors.New("seq: invariant violated")
+
+// Assert returns a sequence of the elements of the provided sequence that checks each element against the invariant as
+// it passes through. For an element violating it, onViolation is called with the element and the element is still
+// yielded; if onViolation is nil, Assert panics instead. It is a cheap runtime contract between pipeline stages that
+// can be removed without changing the pipeline's output. The provided sequence is iterated over lazily when the
+// returned sequence is iterated over.
+func Assert[T any](seq iter.Seq[T], invariant func(T) bool, onViolation func(T)) iter.Seq[T] {
+	return Tap(seq, func(t T) {
+		if invariant(t) {
+			return
+		}
+		if onViolation == nil {
+			panic(fmt.Sprintf("%v: %v", ErrInvariant, t))
+		}
+		onViolation(t)
+	})
+}
+
+// AssertErr is like [Assert] but reports violations in the returned sequence: every element is yielded, paired with
+// nil if it satisfies the invariant, or with an error wrapping [ErrInvariant] if not. The provided sequence is iterated
+// over lazily when the returned sequence is iterated over.
+func AssertErr[T any](seq iter.Seq[T], invariant func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for t := range seq {
+			var err error
+			if !invariant(t) {
+				err = fmt.Errorf("%w: %v", ErrInvariant, t)
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// WeightedReservoir returns a random sample of up to k keys from the sequence, where each key's chance of being chosen
+// is proportional to its weight, using the A-Res algorithm of Efraimidis and Spirakis. Only k keys are held at a time, so
+// the sequence may be arbitrarily long. Pairs whose weight is not positive are never chosen. Random numbers are drawn
+// from src, or from the top-level functions of [math/rand/v2] if src is nil; pass a seeded source for reproducible
+// samples. The order of the returned keys is unspecified. If k is not positive, nil is returned. The sequence is
+// iterated over before WeightedReservoir returns.
+func WeightedReservoir[K any](seq iter.Seq2[K, float64], k int, src *rand.Rand) []K {
+	if k <= 0 {
+		return nil
+	}
+	float := rand.Float64
+	if src != nil {
+		float = src.Float64
+	}
+	type scored struct {
+		key   K
+		score float64
+	}
+	h := topHeap[scored]{n: k, compare: func(a, b scored) int { return cmp.Compare(a.score, b.score) }}
+	for key, w := range seq {
+		if !(w > 0) {
+			continue
+		}
+		// u^(1/w) for a uniform u in (0, 1], kept in log space so small weights don't underflow to zero
+		h.offer(scored{key: key, score: math.Log(1-float()) / w})
+	}
+	return slices.Collect(Map(slices.Values(h.s), func(s scored) K { return s.key }))
+}
+
+// IsEmpty returns true if the sequence has no elements. The sequence is iterated over until its first element when
+// IsEmpty is called, which consumes that element of a single-use source; use [Head] to check such a source while
+// keeping the element.
+func IsEmpty[T any](seq iter.Seq[T]) bool {
+	for range seq {
+		return false
+	}
+	return true
+}
+
+// Head returns the first element of the sequence and a sequence that yields it followed by the rest of the provided
+// sequence, so a single-use source can be checked before committing it to a pipeline. If the sequence is empty, the
+// third return value is false. The provided sequence is iterated over until its first element when Head is called,
+// and is then held open until the returned sequence is iterated over, which may only be done once; iterate over it,
+// even if only to break out of it at once, to release the provided sequence.
+func Head[T any](seq iter.Seq[T]) (T, iter.Seq[T], bool) {
+	next, stop := iter.Pull(seq)
+	first, ok := next()
+	if !ok {
+		stop()
+		return first, func(func(T) bool) {}, false
+	}
+	return first, func(yield func(T) bool) {
+		defer stop()
+		for t, ok := first, true; ok; t, ok = next() {
+			if !yield(t) {
+				return
+			}
+		}
+	}, true
+}
+
+// Sessions returns a sequence of session windows: runs of consecutive elements in which the time between each element
+// and the one before it, as returned by ts, is at most gap. A longer gap closes the current session and starts a new
+// one. Each session is yielded as soon as the element that closes it arrives, so only one session is held at a time.
+// Elements are expected in timestamp order; a timestamp earlier than the previous one never closes a session. The gap
+// must not be negative; if it is, the function will panic. The provided sequence is iterated over lazily when the
+// returned sequence is iterated over.
+func Sessions[T any](seq iter.Seq[T], ts func(T) time.Time, gap time.Duration) iter.Seq[iter.Seq[T]] {
+	if gap < 0 {
+		panic("seq: Sessions gap must not be negative")
+	}
+	return func(yield func(iter.Seq[T]) bool) {
+		var (
+			session []T
+			last    time.Time
+		)
+		for t := range seq {
+			at := ts(t)
+			if len(session) > 0 && at.Sub(last) > gap {
+				if !yield(With(session...)) {
+					return
+				}
+				session = nil
+			}
+			session = append(session, t)
+			last = at
+		}
+		if len(session) > 0 {
+			yield(With(session...))
+		}
+	}
+}
+
+// StateStore holds a fingerprint for each key between runs of [ChangedSince]. Implementations typically persist the
+// fingerprints, for example in a file or database; [MemStateStore] keeps them in memory.
+type StateStore[K comparable] interface {
+	// Get returns the fingerprint stored for the key and true, or false if there is none.
+	Get(K) (uint64, bool)
+	// Set stores the fingerprint for the key.
+	Set(K, uint64)
+}
+
+// MemStateStore is a [StateStore] backed by a map. The zero value is not usable; make one with make or a composite
+// literal.
+type MemStateStore[K comparable] map[K]uint64
+
+// Get implements [StateStore].
+func (m MemStateStore[K]) Get(k K) (uint64, bool) {
+	fp, ok := m[k]
+	return fp, ok
+}
+
+// Set implements [StateStore].
+func (m MemStateStore[K]) Set(k K, fp uint64) {
+	m[k] = fp
+}
+
+// ChangedSince returns a sequence of the elements of the provided sequence that changed since the previous run, each
+// paired with [ChangeAdd] if the store has no fingerprint for its key or [ChangeUpdate] if the stored fingerprint
+// differs. Unchanged elements are skipped. The store's fingerprint is set for every element once the consumer has
+// handled it, including unchanged ones and excluding an element the consumer stops at, so an interrupted run picks up
+// where it left off. Deletions can be derived by a store that records which keys were set during a run: keys it holds
+// that were not set are gone from the source. [Fingerprint] or [FingerprintUnordered] can help build fingerprint
+// functions. The provided sequence is iterated over lazily when the returned sequence is iterated over.
+func ChangedSince[T any, K comparable](seq iter.Seq[T], key func(T) K, fingerprint func(T) uint64, state StateStore[K]) iter.Seq2[T, ChangeKind] {
+	return func(yield func(T, ChangeKind) bool) {
+		for t := range seq {
+			k, fp := key(t), fingerprint(t)
+			prev, ok := state.Get(k)
+			switch {
+			case !ok:
+				if !yield(t, ChangeAdd) {
+					return
+				}
+			case prev != fp:
+				if !yield(t, ChangeUpdate) {
+					return
+				}
+			}
+			state.Set(k, fp)
+		}
+	}
+}
+
+// ToChansByKey returns n channels that together produce the key-value pairs of the sequence, routing each pair to a
+// channel chosen by hashing its key. All pairs with the same key go to the same channel in sequence order, so a worker
+// reading one channel sees every pair for its keys in order. A single goroutine iterates over the sequence, so a slow
+// reader holds up the others once it falls behind. The channels are closed when the sequence is exhausted or the
+// context is canceled. The n must be at least 1; if not, the function will panic.
+func ToChansByKey[K comparable, V any](ctx context.Context, seq iter.Seq2[K, V], n int) []<-chan KV[K, V] {
+	if n < 1 {
+		panic("seq: ToChansByKey n must be at least 1")
+	}
+	chs := make([]chan KV[K, V], n)
+	out := make([]<-chan KV[K, V], n)
+	for i := range chs {
+		chs[i] = make(chan KV[K, V])
+		out[i] = chs[i]
+	}
+	seed := maphash.MakeSeed()
+	go func() {
+		defer func() {
+			for _, ch := range chs {
+				close(ch)
+			}
+		}()
+		for k, v := range seq {
+			if ctx.Err() != nil {
+				return
+			}
+			ch := chs[maphash.Comparable(seed, k)%uint64(n)]
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- KV[K, V]{K: k, V: v}:
+			}
+		}
+	}()
+	return out
+}
+
+// ToMultiMap collects the key-value pairs of the sequence into a map from each key to all of its values, in sequence
+// order. Unlike [maps.Collect], later values for a key are appended rather than overwriting earlier ones. The sequence
+// is iterated over before ToMultiMap returns.
+func ToMultiMap[K comparable, V any](seq iter.Seq2[K, V]) map[K][]V {
+	m := make(map[K][]V)
+	for k, v := range seq {
+		m[k] = append(m[k], v)
+	}
+	return m
+}
+
+// FromMapSorted returns a key-value sequence of the map's entries in ascending key order, for deterministic output
+// where [maps.All] would give a random order. The keys are sorted when the returned sequence is iterated over, and
+// values are read from the map as they are yielded.
+func FromMapSorted[K cmp.Ordered, V any](m map[K]V) iter.Seq2[K, V] {
+	return FromMapFunc(m, cmp.Compare[K])
+}
+
+// FromMapFunc is like [FromMapSorted] but orders the keys with the comparison function.
+func FromMapFunc[K comparable, V any](m map[K]V, compare func(K, K) int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		keys := slices.SortedFunc(maps.Keys(m), compare)
+		for _, k := range keys {
+			v, ok := m[k]
+			if !ok {
+				// deleted while iterating
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// WithDefaults returns a sequence of the key-value pairs of the provided sequence followed, once it is exhausted, by
+// the pair from defaults for every key that did not appear in it, so that every expected key is present even when the
+// source has no pairs for it. The source pairs keep their order; the defaults follow in map order, which is random, so
+// sort the result, for example with [SortedByKey], if the order matters. The provided sequence is iterated over lazily
+// when the returned sequence is iterated over.
+func WithDefaults[K comparable, V any](seq iter.Seq2[K, V], defaults map[K]V) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		seen := make(map[K]struct{}, len(defaults))
+		for k, v := range seq {
+			if _, ok := defaults[k]; ok {
+				seen[k] = struct{}{}
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+		for k, v := range defaults {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// SortedIncremental is like [Sorted] but sorts lazily: the whole sequence is buffered into a heap in O(n) and elements
+// are then removed from the heap one at a time as they are yielded, each in O(log n). A consumer that stops after the
+// first k elements, as with [Take], pays O(n + k log n) instead of a full sort. The order of equal elements is
+// unspecified. The sequence must be finite. The provided sequence is iterated over when the returned sequence is
+// iterated over.
+func SortedIncremental[T cmp.Ordered](seq iter.Seq[T]) iter.Seq[T] {
+	return SortedIncrementalFunc(seq, cmp.Compare[T])
+}
+
+// SortedIncrementalFunc is like [SortedIncremental] but orders the elements with the comparison function.
+func SortedIncrementalFunc[T any](seq iter.Seq[T], compare func(T, T) int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		h := topHeap[T]{s: slices.Collect(seq), compare: compare}
+		h.n = len(h.s)
+		h.heapify()
+		for len(h.s) > 0 {
+			if !yield(h.pop()) {
+				return
+			}
+		}
+	}
+}
+
+// heapify establishes the heap order over the held elements in O(n).
+func (h *topHeap[T]) heapify() {
+	for i := len(h.s)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+// pop removes and returns the smallest held element. The heap must not be empty.
+func (h *topHeap[T]) pop() T {
+	last := len(h.s) - 1
+	t := h.s[0]
+	h.s[0] = h.s[last]
+	var zero T
+	h.s[last] = zero
+	h.s = h.s[:last]
+	h.down(0)
+	return t
+}
+
+// CollectN collects up to n elements of the sequence into a slice and reports whether the sequence had more, giving a
+// safe preview of possibly infinite or huge sequences. To find out if there are more, one element beyond the first n
+// is requested, and discarded, which consumes it from a single-use source. If n is not positive, the slice is nil. The
+// sequence is iterated over when CollectN is called.
+func CollectN[T any](seq iter.Seq[T], n int) ([]T, bool) {
+	var s []T
+	for t := range seq {
+		if len(s) >= n {
+			return s, true
+		}
+		s = append(s, t)
+	}
+	return s, false
+}
+
+// CollectCap collects the elements of the sequence into a new slice preallocated with capacity capHint, avoiding
+// repeated growth when the number of elements is known or can be estimated. The hint only sets the initial capacity;
+// more elements still fit and fewer leave spare capacity. To reuse an existing buffer, use [slices.AppendSeq] with
+// buf[:0]. The sequence is iterated over before CollectCap returns.
+func CollectCap[T any](seq iter.Seq[T], capHint int) []T {
+	return slices.AppendSeq(make([]T, 0, max(capHint, 0)), seq)
+}
+
+// Stream2 is a key-value sequence with chainable methods, for pipelines that read better left to right:
+//
+//	m := NewStream2(rows).Filter(valid).MapValues(normalize).ToMap()
+//
+// Go methods can't introduce type parameters, so the methods keep the key and value types; use the package functions,
+// such as [MapKV], for steps that change them. A Stream2 can be ranged over directly and converted back to an
+// [iter.Seq2] with Seq.
+type Stream2[K comparable, V any] iter.Seq2[K, V]
+
+// NewStream2 returns the sequence as a Stream2.
+func NewStream2[K comparable, V any](seq iter.Seq2[K, V]) Stream2[K, V] {
+	return Stream2[K, V](seq)
+}
+
+// Seq returns the stream as an [iter.Seq2].
+func (s Stream2[K, V]) Seq() iter.Seq2[K, V] {
+	return iter.Seq2[K, V](s)
+}
+
+// Filter is like [FilterKV].
+func (s Stream2[K, V]) Filter(fn func(K, V) bool) Stream2[K, V] {
+	return Stream2[K, V](FilterKV(s.Seq(), fn))
+}
+
+// MapValues is like [MapValues] with a function that keeps the value type.
+func (s Stream2[K, V]) MapValues(fn func(V) V) Stream2[K, V] {
+	return Stream2[K, V](MapValues(s.Seq(), fn))
+}
+
+// Take is like [TakeKV].
+func (s Stream2[K, V]) Take(n int) Stream2[K, V] {
+	return Stream2[K, V](TakeKV(s.Seq(), n))
+}
+
+// SortedByKeyFunc is like [SortedKVFunc], ordering the pairs by key with the comparison function. It takes a
+// function because a method can't require ordered keys; pass [cmp.Compare] for ordered key types.
+func (s Stream2[K, V]) SortedByKeyFunc(compare func(K, K) int) Stream2[K, V] {
+	return Stream2[K, V](SortedKVFunc(s.Seq(), func(a, b KV[K, V]) int { return compare(a.K, b.K) }))
+}
+
+// ToMap collects the stream into a map, as [maps.Collect] does.
+func (s Stream2[K, V]) ToMap() map[K]V {
+	return maps.Collect(s.Seq())
+}
+
+// ParallelFilter returns a sequence of the elements for which the predicate returns true, calling the predicate on up
+// to workers elements concurrently while keeping the elements in sequence order. It suits slow predicates, such as
+// ones making a network request per element. The provided sequence is iterated over on a separate goroutine, lazily
+// when the returned sequence is iterated over, and at most workers elements are read ahead of the consumer. The
+// returned sequence ends early if ctx is canceled, and the context passed to the predicate is canceled when iteration
+// stops; either way every goroutine has exited by the time iteration returns. The workers count must be at least 1;
+// if not, the function will panic.
+func ParallelFilter[T any](ctx context.Context, seq iter.Seq[T], workers int, predicate func(context.Context, T) bool) iter.Seq[T] {
+	if workers < 1 {
+		panic("seq: ParallelFilter workers must be at least 1")
+	}
+	return parallelMap(ctx, seq, workers, func(ctx context.Context, t T) (T, bool) {
+		return t, predicate(ctx, t)
+	})
+}
+
+// parallelMap calls fn on up to workers elements of seq concurrently and yields the results fn keeps in sequence
+// order. The goroutine iterating over seq queues a result channel per element, in order, and the consumer waits on them
+// in turn; a slot is taken before each element is read and held until its result is consumed, so at most workers
+// elements are in flight.
+func parallelMap[T, O any](ctx context.Context, seq iter.Seq[T], workers int, fn func(context.Context, T) (O, bool)) iter.Seq[O] {
+	type result struct {
+		o    O
+		keep bool
+	}
+	return func(yield func(O) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		slots := make(chan struct{}, workers)
+		pending := make(chan chan result, workers)
+		wg.Go(func() {
+			defer close(pending)
+			next, stop := iter.Pull(seq)
+			defer stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case slots <- struct{}{}:
+				}
+				t, ok := next()
+				if !ok {
+					return
+				}
+				res := make(chan result, 1)
+				pending <- res // never blocks: pending has a place for every slot
+				wg.Go(func() {
+					o, keep := fn(ctx, t)
+					res <- result{o: o, keep: keep}
+				})
+			}
+		})
+
+		for res := range pending {
+			r := <-res
+			<-slots
+			if ctx.Err() != nil {
+				return
+			}
+			if r.keep && !yield(r.o) {
+				return
+			}
+		}
+	}
+}
+
+// ParallelForEach calls fn for each element of the sequence, running up to workers calls concurrently. The sequence is
+// iterated over on the calling goroutine; each element is read and then held until a worker is free, so at most one
+// element is read ahead of the running calls. If a call returns an error, the context passed to the other calls is
+// canceled, no further calls are started, and ParallelForEach returns that error once the running calls have returned.
+// If ctx is canceled first, ParallelForEach stops starting calls and returns ctx.Err() once the running calls have
+// returned; if it is canceled only after every element has been handed to a call, the calls' results stand. See
+// [ParallelForEachAll] to keep going past errors. The workers count must be at least 1; if not, the function will
+// panic.
+func ParallelForEach[T any](ctx context.Context, seq iter.Seq[T], workers int, fn func(context.Context, T) error) error {
+	if workers < 1 {
+		panic("seq: ParallelForEach workers must be at least 1")
+	}
+	return parallelForEach(ctx, seq, workers, fn, true)
+}
+
+// ParallelForEachAll is like [ParallelForEach] but an error doesn't stop the other calls: fn is called for every
+// element, unless ctx is canceled, and the errors are returned joined with [errors.Join], in the order the calls
+// returned them. If ctx is canceled before every element has been handed to a call, ctx.Err() is joined after them.
+func ParallelForEachAll[T any](ctx context.Context, seq iter.Seq[T], workers int, fn func(context.Context, T) error) error {
+	if workers < 1 {
+		panic("seq: ParallelForEachAll workers must be at least 1")
+	}
+	return parallelForEach(ctx, seq, workers, fn, false)
+}
+
+// parallelForEach implements ParallelForEach, when failFast is set, and ParallelForEachAll.
+func parallelForEach[T any](ctx context.Context, seq iter.Seq[T], workers int, fn func(context.Context, T) error, failFast bool) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	slots := make(chan struct{}, workers)
+	stopped := false // elements were left uncalled because ctx was done
+	for t := range seq {
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			stopped = true
+			break
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if err := fn(ctx, t); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+				if failFast {
+					cancel()
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if failFast {
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		if stopped {
+			return parent.Err()
+		}
+		return nil
+	}
+	if stopped {
+		errs = append(errs, parent.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// FanOut returns n sequences that together produce the elements of the provided sequence, dealing them out round-robin
+// so that each element goes to exactly one of them. The returned sequences are meant to be iterated over concurrently,
+// typically one per worker goroutine: a single goroutine, started when the first of them is iterated over, iterates
+// over the provided sequence and hands each element over an unbuffered channel, so a slow consumer holds up the others
+// once its turn comes round. A returned sequence that stops early drops out of the rotation; the goroutine exits once
+// the provided sequence is exhausted, every returned sequence has stopped, or ctx is canceled, and the remaining
+// sequences then end. Each returned sequence can only be iterated over once. The n must be at least 1; if not, the
+// function will panic.
+func FanOut[T any](ctx context.Context, seq iter.Seq[T], n int) []iter.Seq[T] {
+	if n < 1 {
+		panic("seq: FanOut n must be at least 1")
+	}
+	next := 0
+	return fanOut(ctx, seq, n, true, func(T) int {
+		i := next
+		next = (next + 1) % n
+		return i
+	})
+}
+
+// FanOutBy is like [FanOut] but sends each element to the sequence chosen by hashing its key, so all elements with the
+// same key go to the same sequence, in order. Elements whose sequence has stopped early are dropped.
+func FanOutBy[T any, K comparable](ctx context.Context, seq iter.Seq[T], n int, key func(T) K) []iter.Seq[T] {
+	if n < 1 {
+		panic("seq: FanOutBy n must be at least 1")
+	}
+	seed := maphash.MakeSeed()
+	return fanOut(ctx, seq, n, false, func(t T) int {
+		return int(maphash.Comparable(seed, key(t)) % uint64(n))
+	})
+}
+
+// fanOut implements FanOut and FanOutBy. route picks the output for each element; if that output has stopped, the
+// element goes to the next live one when reroute is set and is dropped otherwise. Each output closes its done channel
+// when it stops so the sending goroutine never blocks on a consumer that has gone away.
+func fanOut[T any](ctx context.Context, seq iter.Seq[T], n int, reroute bool, route func(T) int) []iter.Seq[T] {
+	chs := make([]chan T, n)
+	dones := make([]chan struct{}, n)
+	for i := range n {
+		chs[i] = make(chan T)
+		dones[i] = make(chan struct{})
+	}
+
+	run := func() {
+		defer func() {
+			for _, ch := range chs {
+				close(ch)
+			}
+		}()
+		stopped := make([]bool, n)
+		live := n
+		for t := range seq {
+			i := route(t)
+			for sent := false; !sent; {
+				if stopped[i] {
+					if !reroute {
+						break
+					}
+					i = (i + 1) % n
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case chs[i] <- t:
+					sent = true
+				case <-dones[i]:
+					stopped[i] = true
+					if live--; live == 0 {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	var start sync.Once
+	out := make([]iter.Seq[T], n)
+	for i := range n {
+		var stop sync.Once
+		out[i] = func(yield func(T) bool) {
+			start.Do(func() { go run() })
+			defer stop.Do(func() { close(dones[i]) })
+			for t := range chs[i] {
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+	return out
+}
+
+// FanIn returns a sequence that iterates over all of the provided sequences concurrently, each on its own goroutine,
+// and yields their elements as they arrive. Elements from one sequence keep their order; elements from different
+// sequences interleave in no particular order (see [Merge] to combine sorted sequences into a sorted one). The
+// goroutines are started each time the returned sequence is iterated over. Iteration ends once every sequence is
+// exhausted or ctx is canceled. When iteration stops early, iteration returns without waiting for the goroutines:
+// each exits once its sequence produces its next element or ends, so sequences that can block, such as ones reading
+// from a channel, should themselves honor ctx (see [FromChanCtx]).
+func FanIn[T any](ctx context.Context, seqs ...iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ch := make(chan T)
+		var wg sync.WaitGroup
+		for _, seq := range seqs {
+			wg.Go(func() {
+				for t := range seq {
+					select {
+					case <-ctx.Done():
+						return
+					case ch <- t:
+					}
+				}
+			})
+		}
+		go func() {
+			wg.Wait()
+			close(ch)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t, ok := <-ch:
+				if !ok || !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// MergeSorted merges any number of sorted sequences into one sorted sequence, like [Merge] does for two. [cmp.Compare]
+// is used to compare elements, and equal elements are yielded in the order of the sequences they come from. A heap of
+// the sequences' next elements keeps the cost per element at O(log k) for k sequences. The provided sequences are
+// iterated over lazily when the returned sequence is iterated over.
+func MergeSorted[T cmp.Ordered](seqs ...iter.Seq[T]) iter.Seq[T] {
+	return MergeSortedFunc(cmp.Compare, seqs...)
+}
+
+// MergeSortedFunc is like [MergeSorted] but uses the provided compare function to compare elements.
+func MergeSortedFunc[T any](compare func(T, T) int, seqs ...iter.Seq[T]) iter.Seq[T] {
+	type head struct {
+		t    T
+		i    int
+		next func() (T, bool)
+	}
+	return func(yield func(T) bool) {
+		h := topHeap[head]{compare: func(a, b head) int {
+			if c := compare(a.t, b.t); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.i, b.i)
+		}}
+		for i, seq := range seqs {
+			next, stop := iter.Pull(seq)
+			defer stop()
+			if t, ok := next(); ok {
+				h.s = append(h.s, head{t: t, i: i, next: next})
+			}
+		}
+		h.heapify()
+		for len(h.s) > 0 {
+			top := &h.s[0]
+			if !yield(top.t) {
+				return
+			}
+			if t, ok := top.next(); ok {
+				top.t = t
+				h.down(0)
+			} else {
+				h.pop()
+			}
+		}
+	}
+}
+
+// Tee returns n sequences that each yield every element of the provided sequence, which is iterated over only once, so
+// a single-use source, such as a channel or a reader, can feed several consumers: Count and Max, say. Elements are
+// buffered for each returned sequence until it yields them, so memory grows with how far the slowest of them has
+// fallen behind; consuming one entirely before starting another buffers the whole sequence. The provided sequence is
+// iterated over lazily when the first returned sequence is iterated over, and released once all of them have stopped
+// or finished. The returned sequences may be iterated over concurrently, but each only once. The n must be at least
+// 1; if not, the function will panic.
+func Tee[T any](seq iter.Seq[T], n int) []iter.Seq[T] {
+	if n < 1 {
+		panic("seq: Tee n must be at least 1")
+	}
+	d := newDealer(seq, n, func(d *dealer[T], _ int, t T) {
+		for i := range d.queues {
+			if d.live[i] {
+				d.queues[i] = append(d.queues[i], t)
+			}
+		}
+	})
+	return d.branches()
+}
+
+// Split returns n sequences that deal out the elements of the provided sequence round-robin: the first element to the
+// first sequence, the second to the second, and so on, wrapping around after n, so sharding is deterministic. Unlike
+// [FanOut] no goroutines or channels are involved; a returned sequence that needs its next element pulls from the
+// provided sequence, buffering the elements meant for the others until they yield them, so memory grows with how
+// unevenly they are consumed. Elements meant for a returned sequence that has stopped are dropped. The provided
+// sequence is iterated over lazily when the first returned sequence is iterated over, and released once all of them
+// have stopped or finished. The returned sequences may be iterated over concurrently, but each only once. The n must
+// be at least 1; if not, the function will panic.
+func Split[T any](seq iter.Seq[T], n int) []iter.Seq[T] {
+	if n < 1 {
+		panic("seq: Split n must be at least 1")
+	}
+	d := newDealer(seq, n, func(d *dealer[T], j int, t T) {
+		if i := j % n; d.live[i] {
+			d.queues[i] = append(d.queues[i], t)
+		}
+	})
+	return d.branches()
+}
+
+// dealer shares one pull of a sequence among n branches, each with its own queue of elements waiting to be yielded.
+// A branch that finds its queue empty pulls the next element and hands it to deal, which appends it to the queues of
+// the branches it is meant for. All state is guarded by mu, so branches may run on different goroutines.
+type dealer[T any] struct {
+	mu      sync.Mutex
+	seq     iter.Seq[T]
+	deal    func(d *dealer[T], j int, t T)
+	next    func() (T, bool)
+	stop    func()
+	pulled  int // elements pulled so far, the index of the next one
+	done    bool
+	queues  [][]T
+	live    []bool
+	running int
+}
+
+func newDealer[T any](seq iter.Seq[T], n int, deal func(d *dealer[T], j int, t T)) *dealer[T] {
+	return &dealer[T]{seq: seq, deal: deal, queues: make([][]T, n), live: make([]bool, n), running: n}
+}
+
+func (d *dealer[T]) branches() []iter.Seq[T] {
+	for i := range d.live {
+		d.live[i] = true
+	}
+	out := make([]iter.Seq[T], len(d.queues))
+	for i := range out {
+		out[i] = func(yield func(T) bool) {
+			defer d.leave(i)
+			for {
+				t, ok := d.take(i)
+				if !ok || !yield(t) {
+					return
+				}
+			}
+		}
+	}
+	return out
+}
+
+// take returns the next element for branch i, pulling from the sequence until one is dealt to it.
+func (d *dealer[T]) take(i int) (T, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var zero T
+	if !d.live[i] {
+		return zero, false
+	}
+	if d.next == nil && !d.done {
+		d.next, d.stop = iter.Pull(d.seq)
+	}
+	for len(d.queues[i]) == 0 {
+		if d.done {
+			return zero, false
+		}
+		t, ok := d.next()
+		if !ok {
+			d.done = true
+			continue
+		}
+		d.deal(d, d.pulled, t)
+		d.pulled++
+	}
+	t := d.queues[i][0]
+	d.queues[i][0] = zero
+	d.queues[i] = d.queues[i][1:]
+	return t, true
+}
+
+// leave retires branch i, dropping its queue, and releases the sequence once every branch has left.
+func (d *dealer[T]) leave(i int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.live[i] {
+		return
+	}
+	d.live[i] = false
+	d.queues[i] = nil
+	if d.running--; d.running == 0 && d.stop != nil {
+		d.stop()
+	}
+}
+
+// Broadcast returns subscribers channels that each receive every element of the sequence, for a fixed set of consumers
+// of one producer; see [NewBroadcaster] for subscribers that come and go. Each channel has a buffer of buf elements. A
+// single goroutine iterates over the sequence and sends each element to every channel in turn, so once a subscriber's
+// buffer is full it holds up the others. The channels are closed when the sequence is exhausted or the context is
+// canceled; a subscriber that stops reading early must cancel the context to release the goroutine. The subscribers
+// count must be at least 1 and buf must not be negative; if not, the function will panic.
+func Broadcast[T any](ctx context.Context, seq iter.Seq[T], subscribers int, buf int) []<-chan T {
+	if subscribers < 1 {
+		panic("seq: Broadcast subscribers must be at least 1")
+	}
+	if buf < 0 {
+		panic("seq: Broadcast buf must not be negative")
+	}
+	chs := make([]chan T, subscribers)
+	out := make([]<-chan T, subscribers)
+	for i := range chs {
+		chs[i] = make(chan T, buf)
+		out[i] = chs[i]
+	}
+	go func() {
+		defer func() {
+			for _, ch := range chs {
+				close(ch)
+			}
+		}()
+		for t := range seq {
+			for _, ch := range chs {
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- t:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Buffer returns a sequence that iterates over the provided sequence on a separate goroutine, reading up to n elements
+// ahead of the consumer, so a slow producer and a slow consumer can work at the same time. The goroutine is started
+// each time the returned sequence is iterated over. When iteration stops early, the goroutine is told to stop and
+// iteration returns once it has: after the element it is producing, if any, so a producer that blocks delays it. The
+// n must be at least 1; if not, the function will panic.
+func Buffer[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	if n < 1 {
+		panic("seq: Buffer n must be at least 1")
+	}
+	return func(yield func(T) bool) {
+		ch := make(chan T, n)
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		defer func() {
+			close(done)
+			wg.Wait()
+		}()
+		wg.Go(func() {
+			defer close(ch)
+			for t := range seq {
+				select {
+				case <-done:
+					return
+				case ch <- t:
+				}
+			}
+		})
+		for t := range ch {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Bridge returns a sequence that flattens a channel of channels: it receives each inner channel in turn and yields its
+// values until it is closed, then moves on to the next, so a stream produced in generations, such as a connection that
+// is re-established, reads as one sequence. The sequence ends when the outer channel is closed or the context is
+// canceled; cancellation abandons a blocked receive on either channel, as with [FromChanCtx].
+func Bridge[T any](ctx context.Context, chs <-chan (<-chan T)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for ch := range FromChanCtx(ctx, chs) {
+			for t := range FromChanCtx(ctx, ch) {
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -2,12 +2,21 @@ package seq
 
 import (
 	"cmp"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"iter"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -2206,3 +2215,1885 @@ func ExampleMapToKV_stopEarly() {
 	// Output:
 	// 1 1
 }
+
+func ExampleShared() {
+	s := Shared(With(1, 2, 3, 4, 5))
+
+	// each element goes to exactly one consumer, so consumers can be run from different goroutines to divide the
+	// work among them
+	first := s.Seq()
+	for v := range first {
+		fmt.Println("first", v)
+		if v == 2 {
+			break
+		}
+	}
+	for v := range s.Seq() {
+		fmt.Println("second", v)
+	}
+
+	// Output:
+	// first 1
+	// first 2
+	// second 3
+	// second 4
+	// second 5
+}
+
+func ExampleSharedSeq_Stop() {
+	s := Shared(With(1, 2, 3))
+
+	for v := range s.Seq() {
+		fmt.Println(v)
+		break
+	}
+	s.Stop()
+	fmt.Println(slices.Collect(s.Seq()))
+
+	// Output:
+	// 1
+	// []
+}
+
+func ExampleMergeAdjacentKV() {
+	type tKV = KV[string, int]
+	i := WithKV(tKV{K: "a", V: 1}, tKV{K: "a", V: 2}, tKV{K: "b", V: 3}, tKV{K: "a", V: 4}, tKV{K: "a", V: 5})
+
+	sum := func(a, b int) int { return a + b }
+	for k, v := range MergeAdjacentKV(i, sum) {
+		fmt.Println(k, v)
+	}
+
+	for k, v := range MergeAdjacentKV(i, sum) {
+		fmt.Println(k, v)
+		break
+	}
+
+	// Output:
+	// a 3
+	// b 3
+	// a 9
+	// a 3
+}
+
+func ExampleSwitch() {
+	routes := Switch(With(1, 2, 3, 4, 5, 6, 15), []Case[int]{
+		{Name: "fizzbuzz", Match: func(v int) bool { return v%15 == 0 }},
+		{Name: "fizz", Match: func(v int) bool { return v%3 == 0 }},
+		{Name: "buzz", Match: func(v int) bool { return v%5 == 0 }},
+		{Name: "other"}, // no Match: the default case
+	})
+
+	fmt.Println(slices.Collect(routes["fizzbuzz"]))
+	fmt.Println(slices.Collect(routes["fizz"]))
+	fmt.Println(slices.Collect(routes["buzz"]))
+	fmt.Println(slices.Collect(routes["other"]))
+
+	// Output:
+	// [15]
+	// [3 6]
+	// [5]
+	// [1 2 4]
+}
+
+func ExampleHeartbeat() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a source that keeps up never needs a heartbeat
+	fmt.Println(slices.Collect(Heartbeat(ctx, With(1, 2, 3), time.Hour, -1)))
+
+	// a source that never produces only yields heartbeats
+	idle := FromChanCtx(ctx, make(chan int))
+	fmt.Println(slices.Collect(Take(Heartbeat(ctx, idle, time.Millisecond, -1), 3)))
+
+	// Output:
+	// [1 2 3]
+	// [-1 -1 -1]
+}
+
+func ExampleSum_durations() {
+	fmt.Println(Sum(With(time.Second, 2*time.Second, 500*time.Millisecond)))
+
+	// Output:
+	// 3.5s
+}
+
+func ExampleAvgDuration() {
+	fmt.Println(AvgDuration(With(time.Second, 2*time.Second)))
+	fmt.Println(AvgDuration(With[time.Duration]()))
+
+	// Output:
+	// 1.5s true
+	// 0s false
+}
+
+func ExampleTakeWithinBudget() {
+	type job = KV[time.Duration, string]
+	jobs := WithKV(
+		job{K: 2 * time.Second, V: "a"},
+		job{K: 3 * time.Second, V: "b"},
+		job{K: 4 * time.Second, V: "c"},
+		job{K: time.Second, V: "d"},
+	)
+
+	fmt.Println(slices.Collect(TakeWithinBudget(jobs, 5*time.Second)))
+	fmt.Println(slices.Collect(TakeWithinBudget(jobs, time.Second)))
+
+	for v := range TakeWithinBudget(jobs, time.Minute) {
+		fmt.Println(v)
+		break
+	}
+
+	// Output:
+	// [a b]
+	// []
+	// a
+}
+
+func ExampleSingleUse() {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	s := SingleUse(FromChan(ch), "numbers")
+
+	fmt.Println(slices.Collect(s))
+
+	defer func() {
+		msg := fmt.Sprint(recover())
+		fmt.Println(msg[:strings.Index(msg, ";")])
+	}()
+	Count(s)
+
+	// Output:
+	// [1 2]
+	// seq: single-use sequence "numbers" iterated more than once
+}
+
+func ExampleFlatMapKV() {
+	type tKV = KV[string, int]
+	i := WithKV(tKV{K: "a", V: 2}, tKV{K: "b", V: 1})
+
+	expanded := FlatMapKV(i, func(k string, v int) iter.Seq2[int, string] {
+		return Enumerate(Repeat(v, k))
+	})
+	for k, v := range expanded {
+		fmt.Println(k, v)
+	}
+
+	for k, v := range expanded {
+		fmt.Println(k, v)
+		break
+	}
+
+	// Output:
+	// 0 a
+	// 1 a
+	// 0 b
+	// 0 a
+}
+
+func ExampleReplay() {
+	type event = KV[time.Time, string]
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := WithKV(
+		event{K: start, V: "connect"},
+		event{K: start.Add(20 * time.Millisecond), V: "request"},
+		event{K: start.Add(40 * time.Millisecond), V: "disconnect"},
+	)
+
+	// replayed ten times faster than recorded, so this takes about 4ms
+	for v := range Replay(events, 10) {
+		fmt.Println(v)
+	}
+
+	// Output:
+	// connect
+	// request
+	// disconnect
+}
+
+func ExampleToChanClose() {
+	ch, stop := ToChanClose(With(1, 2, 3))
+
+	fmt.Println(<-ch)
+	// abandon the channel without draining it; stop lets the producing goroutine exit
+	stop()
+
+	for range ch {
+	}
+	fmt.Println("closed")
+
+	// Output:
+	// 1
+	// closed
+}
+
+func ExampleExpireKV() {
+	type entry = KV[string, time.Time]
+	i := WithKV(
+		entry{K: "fresh", V: time.Now()},
+		entry{K: "stale", V: time.Now().Add(-time.Hour)},
+	)
+
+	identity := func(t time.Time) time.Time { return t }
+	fmt.Println(slices.Collect(IterK(ExpireKV(i, identity, time.Minute))))
+
+	// Output:
+	// [fresh]
+}
+
+func ExampleExpireKVAt() {
+	type event struct {
+		Name string
+		At   time.Time
+	}
+	type entry = KV[string, event]
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	i := WithKV(
+		entry{K: "a", V: event{Name: "login", At: base.Add(-2 * time.Minute)}},
+		entry{K: "b", V: event{Name: "click", At: base.Add(-30 * time.Second)}},
+		entry{K: "c", V: event{Name: "logout", At: base.Add(-time.Minute)}},
+	)
+
+	clock := func() time.Time { return base }
+	at := func(e event) time.Time { return e.At }
+	for k, v := range ExpireKVAt(i, at, time.Minute, clock) {
+		fmt.Println(k, v.Name)
+	}
+
+	// Output:
+	// b click
+	// c logout
+}
+
+func ExampleCycleN() {
+	fmt.Println(slices.Collect(CycleN(With(1, 2), 3)))
+	fmt.Println(slices.Collect(CycleN(With(1, 2), 0)))
+	fmt.Println(slices.Collect(Take(CycleN(With(1, 2), 3), 3)))
+
+	// Output:
+	// [1 2 1 2 1 2]
+	// []
+	// [1 2 1]
+}
+
+func ExampleCycleKVN() {
+	type tKV = KV[string, int]
+	i := WithKV(tKV{K: "a", V: 1}, tKV{K: "b", V: 2})
+
+	for k, v := range CycleKVN(i, 2) {
+		fmt.Println(k, v)
+	}
+	fmt.Println(CountKV(CycleKVN(i, -1)))
+
+	// Output:
+	// a 1
+	// b 2
+	// a 1
+	// b 2
+	// 0
+}
+
+func ExampleMapWithSummary() {
+	lines := With("a", "bb", "ccc")
+
+	upper, totalLen := MapWithSummary(lines, strings.ToUpper, 0, func(n int, s string) int {
+		return n + len(s)
+	})
+
+	fmt.Println(slices.Collect(upper))
+	fmt.Println(totalLen())
+
+	// the summary covers only what was consumed by the latest iteration
+	for range upper {
+		break
+	}
+	fmt.Println(totalLen())
+
+	// Output:
+	// [A BB CCC]
+	// 6
+	// 1
+}
+
+func ExampleRange() {
+	fmt.Println(slices.Collect(Range(0, 5)))
+	fmt.Println(slices.Collect(Range(5, 0)))
+	fmt.Println(slices.Collect(Take(Range(uint8(250), 255), 2)))
+
+	// Output:
+	// [0 1 2 3 4]
+	// []
+	// [250 251]
+}
+
+func ExampleRangeStep() {
+	fmt.Println(slices.Collect(RangeStep(0, 10, 3)))
+	fmt.Println(slices.Collect(RangeStep(5, 0, -2)))
+	fmt.Println(slices.Collect(RangeStep(0, 5, -1)))
+
+	// stops instead of wrapping around when the next value would overflow
+	fmt.Println(slices.Collect(RangeStep[int8](100, 127, 20)))
+
+	// Output:
+	// [0 3 6 9]
+	// [5 3 1]
+	// []
+	// [100 120]
+}
+
+func ExampleSkipUntil() {
+	log := With("boot", "BEGIN", "a", "b", "END", "c")
+
+	fmt.Println(slices.Collect(SkipUntil(log, "BEGIN")))
+	fmt.Println(slices.Collect(SkipUntil(log, "missing")))
+
+	// Output:
+	// [BEGIN a b END c]
+	// []
+}
+
+func ExampleResumeAfter() {
+	log := With("boot", "BEGIN", "a", "b", "END", "c")
+
+	fmt.Println(slices.Collect(ResumeAfter(log, "BEGIN")))
+	fmt.Println(slices.Collect(TakeUntil(ResumeAfter(log, "BEGIN"), "END")))
+	fmt.Println(slices.Collect(Take(ResumeAfter(log, "BEGIN"), 1)))
+
+	// Output:
+	// [a b END c]
+	// [a b]
+	// [a]
+}
+
+func ExampleTakeUntil() {
+	log := With("a", "b", "END", "c")
+
+	fmt.Println(slices.Collect(TakeUntil(log, "END")))
+	fmt.Println(slices.Collect(TakeUntil(log, "missing")))
+
+	// Output:
+	// [a b]
+	// [a b END c]
+}
+
+func ExampleSpy() {
+	var audit strings.Builder
+	format := func(v int) []byte {
+		return fmt.Appendf(nil, "saw %d\n", v)
+	}
+
+	doubled := Map(Spy(With(1, 2, 3), &audit, format), func(v int) int {
+		return v * 2
+	})
+
+	fmt.Println(slices.Collect(doubled))
+	fmt.Print(audit.String())
+
+	// Output:
+	// [2 4 6]
+	// saw 1
+	// saw 2
+	// saw 3
+}
+
+func ExampleIterate() {
+	powers := Iterate(1, func(v int) int { return v * 2 })
+	fmt.Println(slices.Collect(Take(powers, 6)))
+
+	backoff := Iterate(100*time.Millisecond, func(d time.Duration) time.Duration {
+		return min(d*2, time.Second)
+	})
+	fmt.Println(slices.Collect(Take(backoff, 6)))
+
+	// Output:
+	// [1 2 4 8 16 32]
+	// [100ms 200ms 400ms 800ms 1s 1s]
+}
+
+func ExampleForEachSeq() {
+	var mu sync.Mutex
+	sums := make(map[int]int)
+
+	err := ForEachSeq(context.Background(), Chunk(Range(1, 11), 5), 2, func(ctx context.Context, chunk iter.Seq[int]) error {
+		first, _ := At(chunk, 0)
+		sum := Sum(chunk)
+		mu.Lock()
+		defer mu.Unlock()
+		sums[first] = sum
+		return nil
+	})
+	fmt.Println(sums, err)
+
+	errBad := errors.New("bad chunk")
+	err = ForEachSeq(context.Background(), Chunk(Range(1, 11), 5), 1, func(ctx context.Context, chunk iter.Seq[int]) error {
+		return errBad
+	})
+	fmt.Println(err)
+
+	// Output:
+	// map[1:15 6:40] <nil>
+	// bad chunk
+}
+
+func ExampleUnfold() {
+	// walk a paginated listing using the next page token as the state
+	pages := map[string][]string{
+		"":   {"a", "b"},
+		"p2": {"c"},
+		"p3": {"d", "e"},
+	}
+	next := map[string]string{"": "p2", "p2": "p3"}
+	type cursor struct {
+		token string
+		done  bool
+	}
+
+	fetch := func(c cursor) ([]string, cursor, bool) {
+		if c.done {
+			return nil, c, false
+		}
+		tok, more := next[c.token]
+		return pages[c.token], cursor{token: tok, done: !more}, true
+	}
+	for page := range Unfold(cursor{}, fetch) {
+		fmt.Println(page)
+	}
+
+	fib := Unfold([2]int{0, 1}, func(s [2]int) (int, [2]int, bool) {
+		return s[0], [2]int{s[1], s[0] + s[1]}, s[0] < 20
+	})
+	fmt.Println(slices.Collect(fib))
+
+	// Output:
+	// [a b]
+	// [c]
+	// [d e]
+	// [0 1 1 2 3 5 8 13]
+}
+
+func ExampleGenerate() {
+	r := strings.NewReader("one two three")
+	var word string
+	words := Generate(func() (string, bool) {
+		_, err := fmt.Fscan(r, &word)
+		return word, err == nil
+	})
+
+	fmt.Println(slices.Collect(words))
+
+	// Output:
+	// [one two three]
+}
+
+func ExampleGenerateCtx() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var polls int
+	poll := func(ctx context.Context) (int, bool) {
+		polls++
+		if polls == 3 {
+			cancel()
+		}
+		return polls, true
+	}
+
+	// the value produced by the call that canceled the context is still yielded
+	fmt.Println(slices.Collect(GenerateCtx(ctx, poll)))
+	fmt.Println(slices.Collect(GenerateCtx(ctx, poll)))
+
+	// Output:
+	// [1 2 3]
+	// []
+}
+
+func ExampleOnStop() {
+	logStop := func(consumed int) {
+		fmt.Println("consumer stopped after", consumed)
+	}
+	s := OnStop(With(1, 2, 3, 4), logStop)
+
+	fmt.Println(slices.Collect(Take(s, 2)))
+
+	// running to the end doesn't call the function
+	fmt.Println(slices.Collect(s))
+
+	// Output:
+	// consumer stopped after 2
+	// [1 2]
+	// [1 2 3 4]
+}
+
+func ExamplePairwise() {
+	readings := With(10, 12, 11, 15)
+
+	for prev, cur := range Pairwise(readings) {
+		fmt.Println(prev, cur, cur-prev)
+	}
+
+	fmt.Println(CountKV(Pairwise(With(1))))
+
+	for prev, cur := range Pairwise(readings) {
+		fmt.Println(prev, cur)
+		break
+	}
+
+	// Output:
+	// 10 12 2
+	// 12 11 -1
+	// 11 15 4
+	// 0
+	// 10 12
+}
+
+func ExampleWithIDs() {
+	var n int
+	gen := func() string {
+		n++
+		return fmt.Sprintf("req-%d", n)
+	}
+
+	traced := WithIDs(With("GET /", "POST /login", "GET /health"), gen)
+	visible := FilterKV(traced, func(id, req string) bool {
+		return !strings.HasSuffix(req, "/health")
+	})
+	for id, n := range MapValues(visible, func(req string) int { return len(req) }) {
+		fmt.Println(id, n)
+	}
+
+	// Output:
+	// req-1 5
+	// req-2 11
+}
+
+func ExampleMapValues() {
+	type tKV = KV[string, int]
+	i := WithKV(tKV{K: "a", V: 1}, tKV{K: "b", V: 2})
+
+	for k, v := range MapValues(i, strconv.Itoa) {
+		fmt.Printf("%s %q\n", k, v)
+	}
+
+	for k, v := range MapValues(i, strconv.Itoa) {
+		fmt.Printf("%s %q\n", k, v)
+		break
+	}
+
+	// Output:
+	// a "1"
+	// b "2"
+	// a "1"
+}
+
+func ExampleWithCursor() {
+	type row struct {
+		ID   int
+		Name string
+	}
+	rows := With(row{ID: 7, Name: "ann"}, row{ID: 9, Name: "bob"})
+
+	for c, r := range WithCursor(rows, func(r row) string { return "after:" + strconv.Itoa(r.ID) }) {
+		fmt.Println(c, r.Name)
+	}
+
+	// Output:
+	// after:7 ann
+	// after:9 bob
+}
+
+func ExampleLastCursor() {
+	rows := WithCursor(Range(1, 100), strconv.Itoa)
+	page, next := LastCursor(rows)
+
+	// stream a page of three results, then hand out the cursor to resume from
+	for _, v := range TakeKV(page, 3) {
+		fmt.Println(v)
+	}
+	fmt.Println(next())
+
+	_, next = LastCursor(WithCursor(With[int](), strconv.Itoa))
+	fmt.Println(next())
+
+	// Output:
+	// 1
+	// 2
+	// 3
+	// 3 true
+	//  false
+}
+
+func ExampleBroadcaster() {
+	b := NewBroadcaster(With(1, 2, 3))
+
+	all, _ := b.Subscribe(10, BlockSlow)
+	lossy, _ := b.Subscribe(1, DropSlow)
+	gone, unsubscribe := b.Subscribe(10, BlockSlow)
+	unsubscribe()
+
+	// every subscriber here is buffered, so Run can deliver everything before anyone reads
+	fmt.Println(b.Run(context.Background()))
+
+	fmt.Println(slices.Collect(all))
+	fmt.Println(slices.Collect(lossy))
+	fmt.Println(slices.Collect(gone))
+
+	late, _ := b.Subscribe(10, BlockSlow)
+	fmt.Println(slices.Collect(late))
+
+	// Output:
+	// <nil>
+	// [1 2 3]
+	// [1]
+	// []
+	// []
+}
+
+func ExampleOfType() {
+	events := With[any](1, "two", 3, 4.0, fmt.Stringer(time.Second))
+	fmt.Println(slices.Collect(OfType[int](events)))
+	fmt.Println(slices.Collect(OfType[fmt.Stringer](events)))
+
+	// Output:
+	// [1 3]
+	// [1s]
+}
+
+func ExampleAssertType() {
+	for v, err := range AssertType[int](With[any](1, "two", 3)) {
+		fmt.Println(v, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 0 seq: string is not int
+	// 3 <nil>
+}
+
+func ExampleSorted() {
+	s := Sorted(With(3, 1, 2))
+	fmt.Println(slices.Collect(s), IsSorted(s))
+
+	// Output:
+	// [1 2 3] true
+}
+
+func ExampleSortedFunc() {
+	byLen := func(a, b string) int { return cmp.Compare(len(a), len(b)) }
+	fmt.Println(slices.Collect(SortedFunc(With("ccc", "a", "bb", "b"), byLen)))
+
+	// Output:
+	// [a b bb ccc]
+}
+
+func ExampleDiffSorted() {
+	onlyA, onlyB := DiffSorted(With(1, 2, 2, 4, 6), With(2, 3, 4, 5))
+	fmt.Println(slices.Collect(onlyA))
+	fmt.Println(slices.Collect(onlyB))
+
+	// Output:
+	// [1 2 6]
+	// [3 5]
+}
+
+func ExampleDiffSortedFunc() {
+	byLower := func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) }
+	onlyA, onlyB := DiffSortedFunc(With("a", "B", "c"), With("b", "C", "d"), byLower)
+	fmt.Println(slices.Collect(onlyA))
+	fmt.Println(slices.Collect(onlyB))
+
+	// Output:
+	// [a]
+	// [d]
+}
+
+func ExampleSortedByKey() {
+	for k, v := range SortedByKey(maps.All(map[string]int{"b": 2, "c": 1, "a": 3})) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// a 3
+	// b 2
+	// c 1
+}
+
+func ExampleSortedByValue() {
+	for k, v := range SortedByValue(maps.All(map[string]int{"b": 2, "c": 1, "a": 3})) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// c 1
+	// b 2
+	// a 3
+}
+
+func ExampleSortedKVFunc() {
+	// descending by value, then ascending by key
+	byCount := func(a, b KV[string, int]) int {
+		return cmp.Or(cmp.Compare(b.V, a.V), cmp.Compare(a.K, b.K))
+	}
+	for k, v := range SortedKVFunc(maps.All(map[string]int{"x": 1, "y": 2, "z": 2}), byCount) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// y 2
+	// z 2
+	// x 1
+}
+
+func ExampleRetrySeq() {
+	// a source that drops the connection after every two values
+	errDropped := errors.New("connection dropped")
+	from := 0
+	open := func() (iter.Seq2[int, error], error) {
+		return func(yield func(int, error) bool) {
+			for i := from; i < from+2 && i < 5; i++ {
+				if !yield(i, nil) {
+					return
+				}
+			}
+			if from+2 < 5 {
+				yield(0, errDropped)
+			}
+		}, nil
+	}
+	resume := func(consumed int) {
+		fmt.Println("resuming after", consumed)
+		from = consumed
+	}
+
+	for v, err := range RetrySeq(open, 5, nil, resume) {
+		fmt.Println(v, err)
+	}
+
+	// Output:
+	// 0 <nil>
+	// 1 <nil>
+	// resuming after 2
+	// 2 <nil>
+	// 3 <nil>
+	// resuming after 4
+	// 4 <nil>
+}
+
+func ExampleRetrySeq_exhausted() {
+	attempt := 0
+	open := func() (iter.Seq2[string, error], error) {
+		attempt++
+		return nil, fmt.Errorf("attempt %d: unavailable", attempt)
+	}
+
+	for v, err := range RetrySeq(open, 3, Repeat(3, time.Millisecond), nil) {
+		fmt.Printf("%q %v\n", v, err)
+	}
+
+	// Output:
+	// "" attempt 3: unavailable
+}
+
+func ExampleTopN() {
+	s := With(5, 1, 9, 3, 7, 9)
+	fmt.Println(TopN(s, 3))
+	fmt.Println(BottomN(s, 2))
+	fmt.Println(TopN(s, 10))
+
+	// Output:
+	// [9 9 7]
+	// [1 3]
+	// [9 9 7 5 3 1]
+}
+
+func ExampleTopNFunc() {
+	byLen := func(a, b string) int { return cmp.Compare(len(a), len(b)) }
+	s := With("kiwi", "fig", "banana", "apple", "plum")
+	fmt.Println(TopNFunc(s, 2, byLen))
+	fmt.Println(BottomNFunc(s, 2, byLen))
+
+	// Output:
+	// [banana apple]
+	// [fig kiwi]
+}
+
+func ExampleTopNFunc_ties() {
+	// of elements that compare equal, the ones seen first are kept, in the order they were seen
+	byLen := func(a, b string) int { return cmp.Compare(len(a), len(b)) }
+	fmt.Println(TopNFunc(With("a", "b", "cc"), 2, byLen))
+	fmt.Println(BottomNFunc(With("aa", "bb", "c"), 2, byLen))
+	fmt.Println(TopNFunc(With("x", "y", "z"), 2, byLen))
+
+	// Output:
+	// [cc a]
+	// [c aa]
+	// [x y]
+}
+
+func ExampleTopNFuncKV() {
+	hits := map[string]int{"/": 120, "/about": 7, "/blog": 45, "/login": 60}
+	byHits := func(a, b KV[string, int]) int { return cmp.Compare(a.V, b.V) }
+	fmt.Println(TopNFuncKV(maps.All(hits), 2, byHits))
+	fmt.Println(BottomNFuncKV(maps.All(hits), 1, byHits))
+
+	// Output:
+	// [{/ 120} {/login 60}]
+	// [{/about 7}]
+}
+
+func ExampleFairMergeKV() {
+	// tenant "a" floods its stream, but with a quota of 2 per round it can't starve "b" and "c"
+	noisy := WithKV(KV[string, int]{"a", 1}, KV[string, int]{"a", 2}, KV[string, int]{"a", 3}, KV[string, int]{"a", 4}, KV[string, int]{"a", 5})
+	quiet := WithKV(KV[string, int]{"b", 1}, KV[string, int]{"c", 1}, KV[string, int]{"b", 2})
+	for k, v := range FairMergeKV(context.Background(), map[string]int{"a": 2}, noisy, quiet) {
+		fmt.Print(k, v, " ")
+	}
+	fmt.Println()
+
+	// Output:
+	// a1 b1 a2 c1 a3 b2 a4 a5
+}
+
+func ExampleUniqueBy() {
+	type user struct {
+		ID   int
+		Tags []string
+	}
+	users := With(
+		user{ID: 1, Tags: []string{"admin"}},
+		user{ID: 2},
+		user{ID: 1, Tags: []string{"stale"}},
+	)
+	for u := range UniqueBy(users, func(u user) int { return u.ID }) {
+		fmt.Println(u.ID, u.Tags)
+	}
+
+	// Output:
+	// 1 [admin]
+	// 2 []
+}
+
+func ExampleGzipSeq() {
+	chunks := With([]byte("hello, "), []byte("compressed "), []byte("world"))
+
+	var compressed [][]byte
+	for c, err := range GzipSeq(chunks, gzip.BestCompression) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		compressed = append(compressed, c)
+	}
+
+	var out []byte
+	for c, err := range GunzipSeq(slices.Values(compressed)) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		out = append(out, c...)
+	}
+	fmt.Println(string(out))
+
+	// Output:
+	// hello, compressed world
+}
+
+func ExampleGunzipSeq_truncated() {
+	var compressed []byte
+	for c := range GzipSeq(With([]byte("some data")), gzip.DefaultCompression) {
+		compressed = append(compressed, c...)
+	}
+
+	for _, err := range GunzipSeq(With(compressed[:len(compressed)-4])) {
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	// Output:
+	// unexpected EOF
+}
+
+func ExampleGunzipSeq_empty() {
+	for c, err := range GunzipSeq(With[[]byte]()) {
+		fmt.Println(c, err)
+	}
+	fmt.Println("no chunks, no error")
+
+	// Output:
+	// no chunks, no error
+}
+
+func ExampleUniqueByKey() {
+	// layered configuration: the first layer to set a key wins
+	flags := map[string]string{"level": "debug"}
+	env := map[string]string{"level": "info", "addr": ":9090"}
+	defaults := map[string]string{"level": "warn", "addr": ":8080", "timeout": "5s"}
+
+	layers := ConcatKV(SortedByKey(maps.All(flags)), SortedByKey(maps.All(env)), SortedByKey(maps.All(defaults)))
+	for k, v := range UniqueByKey(layers) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// level debug
+	// addr :9090
+	// timeout 5s
+}
+
+func ExampleSampleStable() {
+	userIDs := Map(Range(0, 1000), strconv.Itoa)
+	id := func(s string) string { return "user-" + s }
+
+	// the same users are sampled every time, and a larger fraction keeps a superset
+	tenth := slices.Collect(SampleStable(userIDs, 0.1, id))
+	again := slices.Collect(SampleStable(userIDs, 0.1, id))
+	half := slices.Collect(SampleStable(userIDs, 0.5, id))
+	fmt.Println(slices.Equal(tenth, again))
+	fmt.Println(All(slices.Values(tenth), func(s string) bool { return slices.Contains(half, s) }))
+	fmt.Println(len(tenth) > 50 && len(tenth) < 150, len(half) > 400 && len(half) < 600)
+
+	// Output:
+	// true
+	// true
+	// true true
+}
+
+func ExampleMapOption() {
+	// 0 is a valid reading, so the zero value can't be used to mean "no reading"
+	parse := func(s string) Option[int] {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return NoValue[int]()
+		}
+		return Some(n)
+	}
+	fmt.Println(slices.Collect(MapOption(With("3", "n/a", "0", "7"), parse)))
+
+	// Output:
+	// [3 0 7]
+}
+
+func ExampleFlattenOption() {
+	opts := Append(SomeSeq(With("a", "")), NoValue[string](), Some("b"))
+	for o := range opts {
+		v, ok := o.Get()
+		fmt.Printf("%q %v\n", v, ok)
+	}
+	fmt.Printf("%q\n", slices.Collect(FlattenOption(opts)))
+
+	// Output:
+	// "a" true
+	// "" true
+	// "" false
+	// "b" true
+	// ["a" "" "b"]
+}
+
+func ExampleCoalesce_time() {
+	// a zero instant in another location is not == time.Time{}, but IsZero says it is zero
+	unset := time.Time{}.In(time.FixedZone("X", 3600))
+	set := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	fmt.Println(Coalesce(With(unset, set)))
+
+	// Output:
+	// 2024-01-02 00:00:00 +0000 UTC true
+}
+
+func ExampleCoalesce_nilPointer() {
+	// *time.Time has IsZero through time.Time, but a nil pointer is simply zero rather than a nil dereference
+	set := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	t, ok := Coalesce(With(nil, &time.Time{}, &set))
+	fmt.Println(t, ok)
+	fmt.Println(CoalesceKV(WithKV(KV[string, *time.Time]{K: "unset"}, KV[string, *time.Time]{K: "set", V: &set})))
+	fmt.Println(IsZero[*time.Time](nil))
+
+	// Output:
+	// 2024-01-02 00:00:00 +0000 UTC true
+	// {set 2024-01-02 00:00:00 +0000 UTC} true
+	// true
+}
+
+func ExampleCoalesceFunc() {
+	type config struct {
+		Name  string
+		Hosts []string
+	}
+	// config has a slice field, so it isn't comparable and can't be used with Coalesce
+	layers := With(config{}, config{Hosts: []string{"a", "b"}}, config{Name: "default"})
+	fmt.Println(CoalesceFunc(layers, IsZero[config]))
+	fmt.Println(CoalesceFunc(layers, func(c config) bool { return c.Name == "" }))
+
+	// Output:
+	// { [a b]} true
+	// {default []} true
+}
+
+func ExampleGroupBy_toMap() {
+	type order struct {
+		ID       int
+		Customer string
+	}
+	orders := With(order{1, "ann"}, order{2, "bob"}, order{3, "ann"})
+
+	// GroupBy yields each key once, so its groups collect directly into a map
+	byCustomer := maps.Collect(GroupBy(orders, func(o order) string { return o.Customer }))
+	fmt.Println(len(byCustomer), byCustomer["ann"], byCustomer["bob"])
+
+	// Output:
+	// 2 [{1 ann} {3 ann}] [{2 bob}]
+}
+
+func ExampleIndex() {
+	type user struct {
+		ID   int
+		Name string
+	}
+	users := With(user{7, "ann"}, user{3, "bob"}, user{9, "cat"})
+	byID := NewIndex(IterKV(users, func(u user) int { return u.ID }))
+
+	// random access without rescanning the sequence
+	for _, id := range []int{3, 4} {
+		u, ok := byID.Get(id)
+		fmt.Println(id, u.Name, ok)
+	}
+
+	// and still a sequence, in the original order
+	fmt.Println(byID.Len(), slices.Collect(byID.Keys()))
+	for id, u := range byID.Seq() {
+		fmt.Println(id, u.Name)
+	}
+
+	// Output:
+	// 3 bob true
+	// 4  false
+	// 3 [7 3 9]
+	// 7 ann
+	// 3 bob
+	// 9 cat
+}
+
+func ExampleDuplicates() {
+	emails := With("a@x.com", "b@x.com", "c@x.com", "b@x.com", "a@x.com", "b@x.com")
+	for email, n := range Duplicates(emails) {
+		fmt.Println(email, n)
+	}
+	fmt.Println(HasDuplicates(emails), HasDuplicates(With(1, 2, 3)))
+
+	// Output:
+	// b@x.com 3
+	// a@x.com 2
+	// true false
+}
+
+func ExampleGroupAdjacent() {
+	// log lines already ordered by day
+	lines := With("2024-01-01 start", "2024-01-01 ok", "2024-01-02 ok", "2024-01-03 warn", "2024-01-03 stop")
+	day := func(s string) string { return s[:10] }
+	for d, group := range GroupAdjacent(lines, day) {
+		fmt.Println(d, Count(group))
+	}
+
+	// Output:
+	// 2024-01-01 2
+	// 2024-01-02 1
+	// 2024-01-03 2
+}
+
+func ExampleWritePartitioned() {
+	dir, err := os.MkdirTemp("", "seq-partitioned")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	type event struct {
+		Day string
+		Msg string
+	}
+	events := With(
+		event{"2024-01-01", "start"},
+		event{"2024-01-02", "ok"},
+		event{"2024-01-01", "stop"},
+	)
+	counts, err := WritePartitioned(events, dir,
+		func(e event) string { return e.Day },
+		func(e event) []byte { return []byte(e.Msg + "\n") },
+		FileSuffix(".txt"),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(counts)
+
+	data, err := os.ReadFile(filepath.Join(dir, "2024-01-01", "part-00000.txt"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(string(data))
+
+	// Output:
+	// map[2024-01-01:2 2024-01-02:1]
+	// start
+	// stop
+}
+
+func ExampleWatchdog() {
+	// a producer that stalls after its second element
+	slow := func(yield func(int) bool) {
+		for i := range 3 {
+			if i == 2 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	var once sync.Once
+	onStall := func(stalledFor time.Duration, consumed int) {
+		once.Do(func() { fmt.Println("stalled after", consumed, "elements") })
+	}
+	for v := range Watchdog(slow, 10*time.Millisecond, onStall) {
+		fmt.Println(v)
+	}
+
+	// Output:
+	// 0
+	// 1
+	// stalled after 2 elements
+	// 2
+}
+
+func ExampleMedian() {
+	fmt.Println(Median(With(5, 1, 3)))
+	fmt.Println(Median(With(4, 1, 3, 2)))
+	fmt.Println(Median(With[int]()))
+
+	// Output:
+	// 3 true
+	// 2.5 true
+	// 0 false
+}
+
+func ExamplePercentile() {
+	latencies := With(12*time.Millisecond, 15*time.Millisecond, 11*time.Millisecond, 250*time.Millisecond, 14*time.Millisecond)
+	p90, _ := Percentile(latencies, 90)
+	fmt.Println(time.Duration(p90))
+	for _, p := range []float64{0, 50, 100} {
+		v, _ := Percentile(latencies, p)
+		fmt.Println(p, time.Duration(v))
+	}
+
+	// Output:
+	// 156ms
+	// 0 11ms
+	// 50 14ms
+	// 100 250ms
+}
+
+func ExampleDiffMaps() {
+	old := map[string]string{"host": "a", "port": "80", "debug": "true"}
+	cur := map[string]string{"host": "b", "port": "80", "tls": "on"}
+
+	for k, c := range SortedByKey(DiffMaps(old, cur)) {
+		fmt.Printf("%s %s %q -> %q\n", c.Kind, k, c.Old, c.New)
+	}
+
+	// Output:
+	// delete debug "true" -> ""
+	// update host "a" -> "b"
+	// add tls "" -> "on"
+}
+
+func ExampleApplyKV() {
+	// keep a replica in sync by shipping only the changes
+	primary := map[string]int{"a": 1, "b": 2}
+	replica := maps.Clone(primary)
+
+	before := maps.Clone(primary)
+	primary["b"] = 20
+	primary["c"] = 3
+	delete(primary, "a")
+
+	ApplyKV(replica, DiffMaps(before, primary))
+	fmt.Println(replica, maps.Equal(replica, primary))
+
+	// Output:
+	// map[b:20 c:3] true
+}
+
+func ExampleVariance() {
+	s := With(2, 4, 4, 4, 5, 5, 7, 9)
+	fmt.Println(Variance(s))
+	fmt.Println(StdDev(s))
+	fmt.Println(StdDev(With[float64]()))
+
+	// Output:
+	// 4 true
+	// 2 true
+	// 0 false
+}
+
+func ExampleFingerprint() {
+	encode := func(s string) []byte { return []byte(s) }
+	sent := With("a", "b", "c")
+
+	n1, sum1 := Fingerprint(sent, encode)
+	n2, sum2 := Fingerprint(With("a", "b", "c"), encode)
+	n3, sum3 := Fingerprint(With("c", "b", "a"), encode)
+	fmt.Println(n1 == n2 && sum1 == sum2, n1 == n3 && sum1 == sum3)
+
+	// element boundaries count: ["ab", "c"] is not ["a", "bc"]
+	_, sum4 := Fingerprint(With("ab", "c"), encode)
+	_, sum5 := Fingerprint(With("a", "bc"), encode)
+	fmt.Println(sum4 == sum5)
+
+	// Output:
+	// true false
+	// false
+}
+
+func ExampleFingerprintUnordered() {
+	encode := func(n int) []byte { return strconv.AppendInt(nil, int64(n), 10) }
+
+	n1, sum1 := FingerprintUnordered(With(1, 2, 2, 3), encode)
+	n2, sum2 := FingerprintUnordered(With(2, 3, 1, 2), encode)
+	n3, sum3 := FingerprintUnordered(With(1, 2, 3, 3), encode)
+	fmt.Println(n1 == n2 && sum1 == sum2, n1 == n3 && sum1 == sum3)
+
+	// Output:
+	// true false
+}
+
+func ExampleSummarize() {
+	fmt.Printf("%+v\n", Summarize(With(2, 4, 4, 4, 5, 5, 7, 9)))
+	fmt.Printf("%+v\n", Summarize(With[float64]()))
+
+	// Output:
+	// {Count:8 Sum:40 Min:2 Max:9 Mean:5 StdDev:2}
+	// {Count:0 Sum:0 Min:0 Max:0 Mean:0 StdDev:0}
+}
+
+func ExampleColumns() {
+	// let the reader accept rows of any length, so short rows are reported by Columns
+	r := csv.NewReader(strings.NewReader("name,qty\nbolt,10\nnut\nwasher,5\n"))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for row, err := range Columns(slices.Values(records), nil) {
+		fmt.Println(row, err)
+	}
+
+	// Output:
+	// map[name:bolt qty:10] <nil>
+	// map[] seq: row 2 has 1 fields, want 2
+	// map[name:washer qty:5] <nil>
+}
+
+func ExampleBind() {
+	type item struct {
+		Name    string
+		Qty     int
+		Price   float64       `csv:"unit_price"`
+		Timeout time.Duration `csv:"timeout"`
+		Added   time.Time     `csv:"added"`
+	}
+	rows := With(
+		[]string{"bolt", "10", "0.25", "1s", "2024-01-02T00:00:00Z"},
+		[]string{"nut", "many", "0.10", "", ""},
+		[]string{"washer", "", "0.05", "500ms", ""},
+	)
+	header := []string{"NAME", "qty", "unit_price", "timeout", "added"}
+
+	for it, err := range Bind[item](rows, header) {
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println(it.Name, it.Qty, it.Price, it.Timeout, it.Added.Format(time.DateOnly))
+	}
+
+	// Output:
+	// bolt 10 0.25 1s 2024-01-02
+	// seq: row 2 column "qty": strconv.ParseInt: parsing "many": invalid syntax
+	// washer 0 0.05 500ms 0001-01-01
+}
+
+func ExampleBind_duplicateColumns() {
+	type user struct {
+		Name  string
+		Email string
+	}
+	rows := With([]string{"ann", "Ann Smith", "ANN@EXAMPLE.COM", "ann@example.com"})
+
+	// Name matches a column exactly, so that one wins; Email matches two columns only ignoring case, so the first does
+	header := []string{"name", "Name", "EMAIL", "email"}
+	for u, err := range Bind[user](rows, header) {
+		fmt.Println(u.Name, u.Email, err)
+	}
+
+	// Output:
+	// Ann Smith ANN@EXAMPLE.COM <nil>
+}
+
+func ExampleJoin() {
+	fmt.Println(Join(Map(Range(1, 4), strconv.Itoa), ", "))
+	fmt.Printf("%q\n", Join(With[string](), ", "))
+
+	// Output:
+	// 1, 2, 3
+	// ""
+}
+
+func ExampleJoinFunc() {
+	fmt.Println(JoinFunc(With(time.Second, time.Minute), " then ", time.Duration.String))
+	fmt.Println(JoinFunc(With(1.5, 2.25), "|", func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) }))
+
+	// Output:
+	// 1s then 1m0s
+	// 1.5|2.2
+}
+
+func ExampleGroupBy2() {
+	type sale struct {
+		Region, Product string
+		Amount          int
+	}
+	sales := With(
+		sale{"eu", "tea", 3},
+		sale{"us", "tea", 5},
+		sale{"eu", "coffee", 2},
+		sale{"eu", "tea", 4},
+	)
+	region := func(s sale) string { return s.Region }
+	product := func(s sale) string { return s.Product }
+
+	for r, products := range GroupBy2(sales, region, product) {
+		fmt.Println(r)
+		for p, rows := range products {
+			fmt.Println(" ", p, Sum(Map(rows, func(s sale) int { return s.Amount })))
+		}
+	}
+
+	// Output:
+	// eu
+	//   tea 7
+	//   coffee 2
+	// us
+	//   tea 5
+}
+
+func ExampleAssert() {
+	ascending := func() func(int) bool {
+		prev, first := 0, true
+		return func(v int) bool {
+			ok := first || v >= prev
+			prev, first = v, false
+			return ok
+		}
+	}
+	onViolation := func(v int) { fmt.Println("out of order:", v) }
+
+	fmt.Println(Sum(Assert(With(1, 3, 2, 4), ascending(), onViolation)))
+
+	// Output:
+	// out of order: 2
+	// 10
+}
+
+func ExampleAssertErr() {
+	positive := func(v int) bool { return v > 0 }
+	for v, err := range AssertErr(With(3, -1, 2), positive) {
+		fmt.Println(v, err, errors.Is(err, ErrInvariant))
+	}
+
+	// Output:
+	// 3 <nil> false
+	// -1 seq: invariant violated: -1 true
+	// 2 <nil> false
+}
+
+func ExampleWeightedReservoir() {
+	// "hot" carries almost all of the weight, so it is all but certain to be sampled
+	weights := WithKV(
+		KV[string, float64]{"hot", 1000},
+		KV[string, float64]{"warm", 1},
+		KV[string, float64]{"cold", 1},
+		KV[string, float64]{"off", 0},
+	)
+	src := rand.New(rand.NewPCG(1, 2))
+	sample := WeightedReservoir(weights, 2, src)
+	fmt.Println(len(sample), slices.Contains(sample, "hot"), slices.Contains(sample, "off"))
+
+	// Output:
+	// 2 true false
+}
+
+func ExampleIsEmpty() {
+	fmt.Println(IsEmpty(With[int]()), IsEmpty(With(0)))
+
+	// Output:
+	// true false
+}
+
+func ExampleHead() {
+	// a single-use source, such as rows from a network stream
+	ch := make(chan string, 3)
+	ch <- "id,name"
+	ch <- "1,ann"
+	ch <- "2,bob"
+	close(ch)
+
+	first, rows, ok := Head(FromChan(ch))
+	fmt.Println(first, ok)
+	if first != "id,name" {
+		fmt.Println("unexpected header")
+		return
+	}
+	// the checked element is still part of the sequence
+	fmt.Println(slices.Collect(rows))
+
+	_, _, ok = Head(FromChan(ch))
+	fmt.Println(ok)
+
+	// Output:
+	// id,name true
+	// [id,name 1,ann 2,bob]
+	// false
+}
+
+func ExampleSessions() {
+	type click struct {
+		At   time.Time
+		Page string
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	clicks := With(
+		click{start, "/"},
+		click{start.Add(2 * time.Minute), "/docs"},
+		click{start.Add(5 * time.Minute), "/docs/api"},
+		click{start.Add(2 * time.Hour), "/"},
+		click{start.Add(2*time.Hour + time.Minute), "/pricing"},
+	)
+
+	for s := range Sessions(clicks, func(c click) time.Time { return c.At }, 30*time.Minute) {
+		fmt.Println(slices.Collect(Map(s, func(c click) string { return c.Page })))
+	}
+
+	// Output:
+	// [/ /docs /docs/api]
+	// [/ /pricing]
+}
+
+func ExampleChangedSince() {
+	type product struct {
+		SKU   string
+		Price int
+	}
+	sku := func(p product) string { return p.SKU }
+	fingerprint := func(p product) uint64 {
+		_, sum := Fingerprint(With(p), func(p product) []byte { return fmt.Appendf(nil, "%s:%d", p.SKU, p.Price) })
+		return sum
+	}
+	state := make(MemStateStore[string])
+
+	run := func(catalog iter.Seq[product]) {
+		for p, kind := range ChangedSince(catalog, sku, fingerprint, state) {
+			fmt.Println(kind, p.SKU, p.Price)
+		}
+		fmt.Println("--")
+	}
+	run(With(product{"a", 10}, product{"b", 20}))
+	run(With(product{"a", 10}, product{"b", 25}, product{"c", 5}))
+	run(With(product{"a", 10}, product{"b", 25}, product{"c", 5}))
+
+	// Output:
+	// add a 10
+	// add b 20
+	// --
+	// update b 25
+	// add c 5
+	// --
+	// --
+}
+
+func ExampleToChansByKey() {
+	events := WithKV(
+		KV[string, int]{"acct-1", 1},
+		KV[string, int]{"acct-2", 1},
+		KV[string, int]{"acct-1", 2},
+		KV[string, int]{"acct-2", 2},
+		KV[string, int]{"acct-1", 3},
+	)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var wg sync.WaitGroup
+	for _, ch := range ToChansByKey(context.Background(), events, 3) {
+		wg.Go(func() {
+			for kv := range ch {
+				mu.Lock()
+				seen[kv.K] = append(seen[kv.K], kv.V)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	// each account's events were handled by one worker, in order
+	fmt.Println(seen["acct-1"], seen["acct-2"])
+
+	// Output:
+	// [1 2 3] [1 2]
+}
+
+func ExampleToMultiMap() {
+	logs := WithKV(
+		KV[string, string]{"web-1", "start"},
+		KV[string, string]{"web-2", "start"},
+		KV[string, string]{"web-1", "error"},
+		KV[string, string]{"web-1", "stop"},
+	)
+	byHost := ToMultiMap(logs)
+	fmt.Println(byHost["web-1"], byHost["web-2"])
+
+	// Output:
+	// [start error stop] [start]
+}
+
+func ExampleFromMapSorted() {
+	for k, v := range FromMapSorted(map[string]int{"b": 2, "c": 3, "a": 1}) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// a 1
+	// b 2
+	// c 3
+}
+
+func ExampleFromMapFunc() {
+	byLenThenAlpha := func(a, b string) int { return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b)) }
+	for k, v := range FromMapFunc(map[string]int{"ccc": 3, "a": 1, "bb": 2, "b": 1}, byLenThenAlpha) {
+		fmt.Println(k, v)
+	}
+
+	// Output:
+	// a 1
+	// b 1
+	// bb 2
+	// ccc 3
+}
+
+func ExampleWithDefaults() {
+	// every category must appear in the report, even with no sales
+	sales := WithKV(KV[string, int]{"books", 12}, KV[string, int]{"music", 3})
+	categories := map[string]int{"books": 0, "games": 0, "music": 0, "toys": 0}
+
+	for category, n := range SortedByKey(WithDefaults(sales, categories)) {
+		fmt.Println(category, n)
+	}
+
+	// Output:
+	// books 12
+	// games 0
+	// music 3
+	// toys 0
+}
+
+func ExampleSortedIncremental() {
+	// only the first three elements are ever fully ordered
+	fmt.Println(slices.Collect(Take(SortedIncremental(With(9, 4, 7, 1, 8, 2, 6)), 3)))
+
+	// Output:
+	// [1 2 4]
+}
+
+func ExampleSortedIncrementalFunc() {
+	desc := func(a, b int) int { return cmp.Compare(b, a) }
+	fmt.Println(slices.Collect(SortedIncrementalFunc(With(3, 1, 2), desc)))
+
+	// Output:
+	// [3 2 1]
+}
+
+func ExampleCollectN() {
+	naturals := Iterate(1, func(v int) int { return v + 1 })
+	fmt.Println(CollectN(naturals, 5))
+	fmt.Println(CollectN(With(1, 2), 5))
+	fmt.Println(CollectN(With(1, 2), 2))
+
+	// Output:
+	// [1 2 3 4 5] true
+	// [1 2] false
+	// [1 2] false
+}
+
+func ExampleCollectCap() {
+	s := CollectCap(Range(0, 3), 100)
+	fmt.Println(s, cap(s))
+
+	// reuse a buffer across batches
+	buf := make([]int, 0, 8)
+	for _, batch := range []iter.Seq[int]{Range(0, 3), Range(10, 12)} {
+		buf = slices.AppendSeq(buf[:0], batch)
+		fmt.Println(buf, cap(buf))
+	}
+
+	// Output:
+	// [0 1 2] 100
+	// [0 1 2] 8
+	// [10 11] 8
+}
+
+func ExampleStream2() {
+	env := map[string]string{"APP_PORT": " 8080 ", "APP_HOST": "localhost", "HOME": "/root", "APP_DEBUG": ""}
+
+	settings := NewStream2(maps.All(env)).
+		Filter(func(k, v string) bool { return strings.HasPrefix(k, "APP_") && v != "" }).
+		MapValues(strings.TrimSpace).
+		SortedByKeyFunc(cmp.Compare[string])
+
+	for k, v := range settings {
+		fmt.Printf("%s=%q\n", k, v)
+	}
+	fmt.Println(len(settings.Take(1).ToMap()))
+
+	// Output:
+	// APP_HOST="localhost"
+	// APP_PORT="8080"
+	// 1
+}
+
+func ExampleParallelFilter() {
+	// reachable stands in for a slow check, such as an HTTP HEAD request per URL
+	reachable := func(ctx context.Context, host string) bool {
+		time.Sleep(time.Millisecond)
+		return !strings.HasSuffix(host, ".invalid")
+	}
+	hosts := With("a.example", "b.invalid", "c.example", "d.example", "e.invalid")
+
+	for host := range ParallelFilter(context.Background(), hosts, 3, reachable) {
+		fmt.Println(host)
+	}
+
+	// Output:
+	// a.example
+	// c.example
+	// d.example
+}
+
+func ExampleParallelForEach() {
+	var total atomic.Int64
+	err := ParallelForEach(context.Background(), Range(1, 101), 8, func(_ context.Context, v int) error {
+		total.Add(int64(v))
+		return nil
+	})
+	fmt.Println(total.Load(), err)
+
+	err = ParallelForEach(context.Background(), With("1", "x", "3"), 2, func(_ context.Context, s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	})
+	fmt.Println(err)
+
+	// Output:
+	// 5050 <nil>
+	// strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleParallelForEachAll() {
+	// one worker, so the errors are joined in sequence order
+	err := ParallelForEachAll(context.Background(), With("1", "x", "3", "y"), 1, func(_ context.Context, s string) error {
+		_, err := strconv.Atoi(s)
+		return err
+	})
+	fmt.Println(err)
+
+	// Output:
+	// strconv.Atoi: parsing "x": invalid syntax
+	// strconv.Atoi: parsing "y": invalid syntax
+}
+
+func ExampleFanOut() {
+	workers := FanOut(context.Background(), Range(0, 9), 3)
+
+	got := make([][]int, len(workers))
+	var wg sync.WaitGroup
+	for i, worker := range workers {
+		wg.Go(func() {
+			got[i] = slices.Collect(worker)
+		})
+	}
+	wg.Wait()
+	fmt.Println(got)
+
+	// Output:
+	// [[0 3 6] [1 4 7] [2 5 8]]
+}
+
+func ExampleFanOutBy() {
+	words := With("apple", "bob", "avocado", "banana", "cherry", "apricot")
+	workers := FanOutBy(context.Background(), words, 2, func(s string) byte { return s[0] })
+
+	got := make([][]string, len(workers))
+	var wg sync.WaitGroup
+	for i, worker := range workers {
+		wg.Go(func() {
+			got[i] = slices.Collect(worker)
+		})
+	}
+	wg.Wait()
+
+	// which worker gets which letter depends on the hash, but no letter is split between workers
+	byLetter := make(map[string][]string)
+	for _, g := range got {
+		for letter, words := range GroupBy(slices.Values(g), func(s string) string { return s[:1] }) {
+			if byLetter[letter] != nil {
+				fmt.Println("split:", letter)
+			}
+			byLetter[letter] = words
+		}
+	}
+	for letter, words := range FromMapSorted(byLetter) {
+		fmt.Println(letter, words)
+	}
+
+	// Output:
+	// a [apple avocado apricot]
+	// b [bob banana]
+	// c [cherry]
+}
+
+func ExampleFanIn() {
+	evens := Filter(Range(0, 10), func(v int) bool { return v%2 == 0 })
+	odds := Filter(Range(0, 10), func(v int) bool { return v%2 == 1 })
+
+	// the interleaving varies from run to run, so sort for display
+	fmt.Println(slices.Sorted(FanIn(context.Background(), evens, odds)))
+
+	// Output:
+	// [0 1 2 3 4 5 6 7 8 9]
+}
+
+func ExampleMergeSorted() {
+	fmt.Println(slices.Collect(MergeSorted(With(1, 4, 7), With(2, 5, 8), With(3, 6, 9), With(0, 10))))
+
+	// Output:
+	// [0 1 2 3 4 5 6 7 8 9 10]
+}
+
+func ExampleMergeSortedFunc() {
+	// time-ordered log lines from several files; ties keep the order of the files
+	type line struct {
+		at  int
+		msg string
+	}
+	a := With(line{1, "a: start"}, line{5, "a: done"})
+	b := With(line{1, "b: start"}, line{3, "b: working"})
+	byTime := func(x, y line) int { return cmp.Compare(x.at, y.at) }
+
+	for l := range MergeSortedFunc(byTime, a, b) {
+		fmt.Println(l.at, l.msg)
+	}
+
+	// Output:
+	// 1 a: start
+	// 1 b: start
+	// 3 b: working
+	// 5 a: done
+}
+
+func ExampleTee() {
+	// a channel can only be read once, but Tee lets both Count and Max see every element
+	ch := make(chan int, 5)
+	for _, v := range []int{3, 1, 4, 1, 5} {
+		ch <- v
+	}
+	close(ch)
+
+	copies := Tee(FromChan(ch), 2)
+	fmt.Println(Count(copies[0]))
+	fmt.Println(Max(copies[1]))
+
+	// Output:
+	// 5
+	// 5 true
+}
+
+func ExampleBroadcast() {
+	subs := Broadcast(context.Background(), With("a", "b", "c"), 2, 3)
+
+	var wg sync.WaitGroup
+	got := make([][]string, len(subs))
+	for i, sub := range subs {
+		wg.Go(func() {
+			got[i] = slices.Collect(FromChan(sub))
+		})
+	}
+	wg.Wait()
+	fmt.Println(got)
+
+	// Output:
+	// [[a b c] [a b c]]
+}
+
+func ExampleBuffer() {
+	// the producer reads up to 2 lines ahead while the consumer handles the current one
+	lines := Map(Range(1, 6), func(i int) string { return "line " + strconv.Itoa(i) })
+	for line := range Take(Buffer(lines, 2), 3) {
+		fmt.Println(line)
+	}
+
+	// Output:
+	// line 1
+	// line 2
+	// line 3
+}
+
+func ExampleSplit() {
+	shards := Split(Range(0, 10), 3)
+	for _, shard := range shards {
+		fmt.Println(slices.Collect(shard))
+	}
+
+	// Output:
+	// [0 3 6 9]
+	// [1 4 7]
+	// [2 5 8]
+}
+
+func ExampleBridge() {
+	// each generation, say one per reconnect, arrives as its own channel
+	gens := make(chan (<-chan int))
+	go func() {
+		defer close(gens)
+		for gen := range 3 {
+			ch := make(chan int)
+			gens <- ch
+			for i := range 2 {
+				ch <- gen*10 + i
+			}
+			close(ch)
+		}
+	}()
+
+	fmt.Println(slices.Collect(Bridge(context.Background(), gens)))
+
+	// Output:
+	// [0 1 10 11 20 21]
+}
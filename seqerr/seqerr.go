@@ -0,0 +1,292 @@
+// Package seqerr provides combinators for error-carrying sequences, iter.Seq2[T, error], the shape used by fallible
+// sources such as files, database rows, and paged HTTP APIs.
+//
+// The combinators here, such as Filter, Map, and Take, treat the error as an error rather than as a second value: a
+// pair with a non-nil error is passed through unchanged, in its original position, and never reaches the provided
+// function. Whether to stop at an error is left to the consumer, which can simply break out of its range loop, or to
+// the terminals and adapters that set a policy: Collect stops at the first error, Partition gathers them all, DropErr
+// and OnErr skip them, FilterErrIs and FilterErrAs remove the expected ones, and Must panics.
+package seqerr
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// SeqE is a sequence of values, each paired with the error, if any, encountered producing it. A pair with a non-nil
+// error carries no meaningful value.
+type SeqE[T any] = iter.Seq2[T, error]
+
+// Filter returns a sequence of the values for which the predicate returns true, along with every error. The provided
+// sequence is iterated over lazily when the returned sequence is iterated over.
+func Filter[T any](seq SeqE[T], predicate func(T) bool) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err != nil || predicate(v) {
+				if !yield(v, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Map returns a sequence of the results of applying fn to each value. Errors from the provided sequence are passed
+// through, and an error returned by fn takes the place of the value it failed on. The provided sequence is iterated
+// over lazily when the returned sequence is iterated over.
+func Map[T, O any](seq SeqE[T], fn func(T) (O, error)) SeqE[O] {
+	return func(yield func(O, error) bool) {
+		for v, err := range seq {
+			var o O
+			if err == nil {
+				o, err = fn(v)
+				if err != nil {
+					o = *new(O)
+				}
+			}
+			if !yield(o, err) {
+				return
+			}
+		}
+	}
+}
+
+// MapErr returns an error-carrying sequence of the results of applying the fallible function fn to each element of a
+// plain sequence, pairing each result with the error fn returned for it. Every element is mapped; see [TryMap] to stop
+// at the first error. Function application happens lazily when the returned sequence is iterated over.
+func MapErr[T, O any](seq iter.Seq[T], fn func(T) (O, error)) SeqE[O] {
+	return func(yield func(O, error) bool) {
+		for t := range seq {
+			if !yield(fn(t)) {
+				return
+			}
+		}
+	}
+}
+
+// TryMap is like [MapErr] but stops after the first error: the failing pair is the last one yielded and the rest of
+// the provided sequence is not iterated over.
+func TryMap[T, O any](seq iter.Seq[T], fn func(T) (O, error)) SeqE[O] {
+	return func(yield func(O, error) bool) {
+		for t := range seq {
+			o, err := fn(t)
+			if !yield(o, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Take returns a sequence of the first n values, along with any errors that come before the nth value; errors don't
+// count towards n. If n is not positive, the returned sequence is empty. The provided sequence is iterated over lazily
+// when the returned sequence is iterated over.
+func Take[T any](seq SeqE[T], n int) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v, err := range seq {
+			if !yield(v, err) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			i++
+			if i == n {
+				return
+			}
+		}
+	}
+}
+
+// Chunk returns a sequence of slices of up to size values. An error ends the chunk being built, which is yielded short
+// before the error so the order of values and errors is preserved; the next chunk starts after the error. Each chunk
+// is a new slice and may be retained. Chunk panics if size is less than 1. The provided sequence is iterated over
+// lazily when the returned sequence is iterated over.
+func Chunk[T any](seq SeqE[T], size int) SeqE[[]T] {
+	if size < 1 {
+		panic("seqerr: Chunk size must be at least 1")
+	}
+	return func(yield func([]T, error) bool) {
+		var chunk []T
+		for v, err := range seq {
+			if err != nil {
+				if len(chunk) > 0 && !yield(chunk, nil) {
+					return
+				}
+				chunk = nil
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			chunk = append(chunk, v)
+			if len(chunk) == size {
+				if !yield(chunk, nil) {
+					return
+				}
+				chunk = nil
+			}
+		}
+		if len(chunk) > 0 {
+			yield(chunk, nil)
+		}
+	}
+}
+
+// Collect collects the values into a slice, stopping at the first error. On error it returns the values collected
+// before it along with the error; the rest of the sequence is not iterated over.
+func Collect[T any](seq SeqE[T]) ([]T, error) {
+	var out []T
+	for v, err := range seq {
+		if err != nil {
+			return out, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Partition consumes the whole sequence, collecting the values of the pairs without an error into a slice and joining
+// the errors, in order, with [errors.Join]. The error is nil if no pair had one.
+func Partition[T any](seq SeqE[T]) ([]T, error) {
+	var out []T
+	var errs []error
+	for v, err := range seq {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, errors.Join(errs...)
+}
+
+// Must returns a sequence of the values that panics at the first error, with an error wrapping it. It is meant for
+// scripts and tests, where a failure should simply end the program. The provided sequence is iterated over lazily when
+// the returned sequence is iterated over.
+func Must[T any](seq SeqE[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v, err := range seq {
+			if err != nil {
+				panic(fmt.Errorf("seqerr: Must: %w", err))
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Ignore returns a sequence of the values, silently dropping the pairs with an error. It is [DropErr] without a
+// callback.
+func Ignore[T any](seq SeqE[T]) iter.Seq[T] {
+	return DropErr(seq, nil)
+}
+
+// DropErr returns a sequence of the values, dropping the pairs with an error. If onErr is not nil it is called with
+// each dropped error, e.g. to log or count it. The provided sequence is iterated over lazily when the returned sequence
+// is iterated over.
+func DropErr[T any](seq SeqE[T], onErr func(error)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v, err := range seq {
+			if err != nil {
+				if onErr != nil {
+					onErr(err)
+				}
+				continue
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// OnErr applies a per-error policy. For each pair with an error, handle decides whether to continue: if it returns
+// true the pair is dropped and iteration continues, otherwise the pair is yielded as the last one, so the consumer sees
+// the error that aborted the sequence. Pairs without an error pass through. The provided sequence is iterated over
+// lazily when the returned sequence is iterated over.
+func OnErr[T any](seq SeqE[T], handle func(error) bool) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err != nil {
+				if handle(err) {
+					continue
+				}
+				yield(v, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FilterErrIs returns a sequence with the pairs whose error matches target, as reported by [errors.Is], removed. Pairs
+// with a nil error or an error that doesn't match are passed through, so benign errors like [io/fs.ErrNotExist] can be
+// ignored while the rest are still propagated. The provided sequence is iterated over lazily when the returned sequence
+// is iterated over.
+func FilterErrIs[T any](seq SeqE[T], target error) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err != nil && errors.Is(err, target) {
+				continue
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// FilterErrAs is like [FilterErrIs] but removes the pairs whose error is of type E, as reported by [errors.As].
+func FilterErrAs[T any, E error](seq SeqE[T]) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			var target E
+			if err != nil && errors.As(err, &target) {
+				continue
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// Result is a value paired with the error, if any, encountered producing it. It lets the pairs of an error-carrying
+// sequence flow through functions that take an iter.Seq, such as seq.Chunk and seq.ToChan; see [ToResults] and
+// [FromResults].
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// ToResults returns a sequence of the pairs as Results. The provided sequence is iterated over lazily when the returned
+// sequence is iterated over.
+func ToResults[T any](seq SeqE[T]) iter.Seq[Result[T]] {
+	return func(yield func(Result[T]) bool) {
+		for v, err := range seq {
+			if !yield(Result[T]{Value: v, Err: err}) {
+				return
+			}
+		}
+	}
+}
+
+// FromResults returns an error-carrying sequence of the values and errors of the Results, undoing [ToResults]. The
+// provided sequence is iterated over lazily when the returned sequence is iterated over.
+func FromResults[T any](seq iter.Seq[Result[T]]) SeqE[T] {
+	return func(yield func(T, error) bool) {
+		for r := range seq {
+			if !yield(r.Value, r.Err) {
+				return
+			}
+		}
+	}
+}
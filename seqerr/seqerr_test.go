@@ -0,0 +1,234 @@
+package seqerr
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+
+	"github.com/freeformz/seq"
+)
+
+// lines stands in for a fallible source such as a file, with a read error after the third line.
+func lines(yield func(string, error) bool) {
+	for _, l := range []string{"1", "2", "x", "3"} {
+		if !yield(l, nil) {
+			return
+		}
+	}
+	if !yield("", errors.New("read failed")) {
+		return
+	}
+	yield("4", nil)
+}
+
+func ExampleMap() {
+	for v, err := range Map(lines, strconv.Atoi) {
+		fmt.Println(v, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 2 <nil>
+	// 0 strconv.Atoi: parsing "x": invalid syntax
+	// 3 <nil>
+	// 0 read failed
+	// 4 <nil>
+}
+
+func ExampleFilter() {
+	for v, err := range Filter(lines, func(s string) bool { return s != "x" }) {
+		fmt.Printf("%q %v\n", v, err)
+	}
+
+	// Output:
+	// "1" <nil>
+	// "2" <nil>
+	// "3" <nil>
+	// "" read failed
+	// "4" <nil>
+}
+
+func ExampleTake() {
+	for v, err := range Take(Map(lines, strconv.Atoi), 3) {
+		fmt.Println(v, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 2 <nil>
+	// 0 strconv.Atoi: parsing "x": invalid syntax
+	// 3 <nil>
+}
+
+func ExampleChunk() {
+	for chunk, err := range Chunk(Filter(lines, func(s string) bool { return s != "x" }), 2) {
+		fmt.Println(chunk, err)
+	}
+
+	// Output:
+	// [1 2] <nil>
+	// [3] <nil>
+	// [] read failed
+	// [4] <nil>
+}
+
+func ExampleSeqE() {
+	// stop at the first error by breaking out of the loop
+	var sum int
+	var err error
+	for v, e := range Map(lines, strconv.Atoi) {
+		if e != nil {
+			err = e
+			break
+		}
+		sum += v
+	}
+	fmt.Println(sum, err)
+
+	// Output:
+	// 3 strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleMapErr() {
+	for n, err := range MapErr(slices.Values([]string{"1", "x", "3"}), strconv.Atoi) {
+		fmt.Println(n, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 0 strconv.Atoi: parsing "x": invalid syntax
+	// 3 <nil>
+}
+
+func ExampleTryMap() {
+	for n, err := range TryMap(slices.Values([]string{"1", "x", "3"}), strconv.Atoi) {
+		fmt.Println(n, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 0 strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleCollect() {
+	fmt.Println(Collect(Take(Map(lines, strconv.Atoi), 2)))
+	fmt.Println(Collect(Map(lines, strconv.Atoi)))
+
+	// Output:
+	// [1 2] <nil>
+	// [1 2] strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExamplePartition() {
+	nums, err := Partition(Map(lines, strconv.Atoi))
+	fmt.Println(nums)
+	fmt.Println(err)
+
+	// Output:
+	// [1 2 3 4]
+	// strconv.Atoi: parsing "x": invalid syntax
+	// read failed
+}
+
+func ExampleDropErr() {
+	var skipped int
+	nums := DropErr(Map(lines, strconv.Atoi), func(error) { skipped++ })
+	fmt.Println(slices.Collect(nums), skipped)
+
+	// Output:
+	// [1 2 3 4] 2
+}
+
+func ExampleOnErr() {
+	// skip values that fail to parse, abort on anything else
+	skipSyntax := func(err error) bool { return errors.Is(err, strconv.ErrSyntax) }
+	for v, err := range OnErr(Map(lines, strconv.Atoi), skipSyntax) {
+		fmt.Println(v, err)
+	}
+
+	// Output:
+	// 1 <nil>
+	// 2 <nil>
+	// 3 <nil>
+	// 0 read failed
+}
+
+func ExampleFilterErrIs() {
+	type result = seq.KV[string, error]
+	results := seq.WithKV(
+		result{K: "a.txt", V: nil},
+		result{K: "b.txt", V: fmt.Errorf("open b.txt: %w", fs.ErrNotExist)},
+		result{K: "c.txt", V: fs.ErrPermission},
+	)
+
+	for name, err := range FilterErrIs(results, fs.ErrNotExist) {
+		fmt.Println(name, err)
+	}
+
+	// Output:
+	// a.txt <nil>
+	// c.txt permission denied
+}
+
+func ExampleFilterErrAs() {
+	type result = seq.KV[string, error]
+	results := seq.WithKV(
+		result{K: "a", V: nil},
+		result{K: "b", V: &strconv.NumError{Func: "Atoi", Num: "b", Err: strconv.ErrSyntax}},
+		result{K: "c", V: errors.New("fatal")},
+	)
+
+	for k, err := range FilterErrAs[string, *strconv.NumError](results) {
+		fmt.Println(k, err)
+	}
+
+	// Output:
+	// a <nil>
+	// c fatal
+}
+
+func ExampleToResults() {
+	// Results carry the errors through seq.Chunk, which only takes an iter.Seq
+	for chunk := range seq.Chunk(ToResults(Map(lines, strconv.Atoi)), 4) {
+		for v, err := range FromResults(chunk) {
+			fmt.Println(v, err)
+		}
+		fmt.Println("--")
+	}
+
+	// Output:
+	// 1 <nil>
+	// 2 <nil>
+	// 0 strconv.Atoi: parsing "x": invalid syntax
+	// 3 <nil>
+	// --
+	// 0 read failed
+	// 4 <nil>
+	// --
+}
+
+func ExampleMust() {
+	fmt.Println(slices.Collect(Must(Take(Map(lines, strconv.Atoi), 2))))
+
+	defer func() {
+		fmt.Println("recovered:", recover())
+	}()
+	for n := range Must(Map(lines, strconv.Atoi)) {
+		fmt.Println(n)
+	}
+
+	// Output:
+	// [1 2]
+	// 1
+	// 2
+	// recovered: seqerr: Must: strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleIgnore() {
+	fmt.Println(slices.Collect(Ignore(Map(lines, strconv.Atoi))))
+
+	// Output:
+	// [1 2 3 4]
+}
@@ -0,0 +1,138 @@
+// Package source opens sequences of lines from URL-style specs, such as file:///x.txt, stdin:, or range:1-100, so that
+// command line tools can accept any registered kind of input without wiring up each one themselves. Additional kinds
+// of source are added with Register.
+package source
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Func opens the sequence of lines described by a parsed source spec. See [Register] and [Open].
+type Func func(ctx context.Context, spec *url.URL) (iter.Seq2[string, error], error)
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]Func{
+		"file":  openFile,
+		"stdin": openStdin,
+		"range": openRange,
+	}
+)
+
+// Register makes a source available to [Open] under the URL scheme. The file, stdin, and range schemes are registered
+// by default. Register panics if the scheme is empty, fn is nil, or the scheme is already registered.
+func Register(scheme string, fn Func) {
+	if scheme == "" || fn == nil {
+		panic("source: Register requires a scheme and a function")
+	}
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, ok := sources[scheme]; ok {
+		panic("source: Register called twice for scheme " + scheme)
+	}
+	sources[scheme] = fn
+}
+
+// Open returns the sequence of lines described by the URL-style spec, using the source registered for its scheme
+// with [Register]. The default sources are:
+//
+//	file:///path/to/file  the lines of the file, opened each time the sequence is iterated over
+//	file:relative/path    as above, relative to the working directory
+//	stdin:                the lines of standard input; the sequence can only be iterated over once
+//	range:1-100           the decimal integers from the first to the second non-negative bound, inclusive
+//
+// Errors reading a line are yielded with an empty line, after which the sequence ends. Sequences from the default
+// sources also end when ctx is canceled.
+func Open(ctx context.Context, spec string) (iter.Seq2[string, error], error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	sourcesMu.RLock()
+	fn, ok := sources[u.Scheme]
+	sourcesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("source: no source registered for %q", spec)
+	}
+	return fn(ctx, u)
+}
+
+func openFile(ctx context.Context, spec *url.URL) (iter.Seq2[string, error], error) {
+	path := spec.Path
+	if path == "" {
+		path = spec.Opaque // relative paths like file:data.txt
+	}
+	if path == "" {
+		return nil, fmt.Errorf("source: file source %q has no path", spec)
+	}
+	return func(yield func(string, error) bool) {
+		f, err := os.Open(path)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer f.Close()
+		for line, err := range readLines(ctx, f) {
+			if !yield(line, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+func openStdin(ctx context.Context, _ *url.URL) (iter.Seq2[string, error], error) {
+	return readLines(ctx, os.Stdin), nil
+}
+
+func openRange(ctx context.Context, spec *url.URL) (iter.Seq2[string, error], error) {
+	from, to, ok := strings.Cut(spec.Opaque, "-")
+	start, err1 := strconv.Atoi(from)
+	end, err2 := strconv.Atoi(to)
+	if !ok || err1 != nil || err2 != nil || start < 0 || end < 0 {
+		return nil, fmt.Errorf("source: range source %q is not of the form range:<from>-<to>", spec)
+	}
+	return func(yield func(string, error) bool) {
+		if start > end {
+			return
+		}
+		// the bound is inclusive, so stop after yielding end rather than at end+1, which overflows for math.MaxInt
+		for i := start; ; i++ {
+			if err := ctx.Err(); err != nil {
+				yield("", err)
+				return
+			}
+			if !yield(strconv.Itoa(i), nil) || i == end {
+				return
+			}
+		}
+	}, nil
+}
+
+// readLines yields the lines of r without their line endings, ending after the first read error or when ctx is
+// canceled, which is yielded with an empty line.
+func readLines(ctx context.Context, r io.Reader) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if err := ctx.Err(); err != nil {
+				yield("", err)
+				return
+			}
+			if !yield(scanner.Text(), nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func ExampleOpen() {
+	nums, err := Open(context.Background(), "range:1-5")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for line, err := range nums {
+		fmt.Println(line, err)
+	}
+
+	f, err := os.CreateTemp("", "seq-example-*.txt")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "first\nsecond\n")
+	f.Close()
+
+	lines, err := Open(context.Background(), "file://"+filepath.ToSlash(f.Name()))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var got []string
+	for line, err := range lines {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		got = append(got, line)
+	}
+	fmt.Println(got)
+
+	_, err = Open(context.Background(), "nope:x")
+	fmt.Println(err)
+
+	// Output:
+	// 1 <nil>
+	// 2 <nil>
+	// 3 <nil>
+	// 4 <nil>
+	// 5 <nil>
+	// [first second]
+	// source: no source registered for "nope:x"
+}
+
+func ExampleOpen_maxIntBound() {
+	// the inclusive upper bound may be math.MaxInt
+	spec := fmt.Sprintf("range:%d-%d", math.MaxInt-1, math.MaxInt)
+	nums, err := Open(context.Background(), spec)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var n int
+	for line, err := range nums {
+		fmt.Println(line == strconv.Itoa(math.MaxInt-1+n), err)
+		n++
+	}
+	fmt.Println(n)
+
+	// Output:
+	// true <nil>
+	// true <nil>
+	// 2
+}
@@ -5,8 +5,22 @@ package stresstest
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"iter"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -14,6 +28,10 @@ import (
 	"time"
 
 	"github.com/freeformz/seq"
+	"github.com/freeformz/seq/pipeline"
+	"github.com/freeformz/seq/seqerr"
+	"github.com/freeformz/seq/source"
+	"github.com/freeformz/seq/wire"
 )
 
 // mustPanic fails the test if fn does not panic.
@@ -297,3 +315,1347 @@ func TestFromChanCtxCanceledCtxWinsOverReadyChannel(t *testing.T) {
 		}
 	}
 }
+
+func TestSharedConcurrentConsumers(t *testing.T) {
+	// Every element of the shared sequence must be handed to exactly one consumer, even with consumers racing each
+	// other and some of them stopping early.
+	const n, consumers = 10000, 16
+	s := seq.Shared(func(yield func(int) bool) {
+		for i := range n {
+			if !yield(i) {
+				return
+			}
+		}
+	})
+	defer s.Stop()
+
+	results := make([][]int, consumers)
+	var wg sync.WaitGroup
+	for c := range consumers {
+		wg.Go(func() {
+			for v := range s.Seq() {
+				results[c] = append(results[c], v)
+				if c%4 == 0 && len(results[c]) == 10 {
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool, n)
+	for _, out := range results {
+		for _, v := range out {
+			if seen[v] {
+				t.Fatalf("Shared handed out %d more than once", v)
+			}
+			seen[v] = true
+		}
+	}
+	if len(seen) != n {
+		t.Errorf("Shared handed out %d elements, want %d", len(seen), n)
+	}
+}
+
+func TestSwitchSinglePassOverOneShotSource(t *testing.T) {
+	// Every route must get its elements from a source that can only be iterated over once, whether the routes are
+	// drained one after another or concurrently.
+	route := func() map[string]iter.Seq[int] {
+		ch := make(chan int, 100)
+		for i := range 100 {
+			ch <- i
+		}
+		close(ch)
+		return seq.Switch(seq.SingleUse(seq.FromChan(ch), "switch source"), []seq.Case[int]{
+			{Name: "fizz", Match: func(v int) bool { return v%3 == 0 }},
+			{Name: "buzz", Match: func(v int) bool { return v%5 == 0 }},
+			{Name: "other"},
+		})
+	}
+	want := map[string][]int{
+		"fizz":  slices.Collect(seq.Filter(seq.Range(0, 100), func(v int) bool { return v%3 == 0 })),
+		"buzz":  slices.Collect(seq.Filter(seq.Range(0, 100), func(v int) bool { return v%3 != 0 && v%5 == 0 })),
+		"other": slices.Collect(seq.Filter(seq.Range(0, 100), func(v int) bool { return v%3 != 0 && v%5 != 0 })),
+	}
+
+	routes := route()
+	for _, name := range []string{"other", "buzz", "fizz"} {
+		if got := slices.Collect(routes[name]); !slices.Equal(got, want[name]) {
+			t.Errorf("drained in turn, route %q got %v, want %v", name, got, want[name])
+		}
+	}
+
+	routes = route()
+	got := make(map[string][]int, len(routes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, r := range routes {
+		wg.Go(func() {
+			vs := slices.Collect(r)
+			mu.Lock()
+			got[name] = vs
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	for name, vs := range want {
+		if !slices.Equal(got[name], vs) {
+			t.Errorf("drained concurrently, route %q got %v, want %v", name, got[name], vs)
+		}
+	}
+}
+
+func TestHeartbeatPanicsOnNonPositiveInterval(t *testing.T) {
+	mustPanic(t, "Heartbeat every=0", func() { seq.Heartbeat(t.Context(), seq.With(1), 0, 0) })
+	mustPanic(t, "Heartbeat every=-1", func() { seq.Heartbeat(t.Context(), seq.With(1), -time.Second, 0) })
+}
+
+func TestHeartbeatTiming(t *testing.T) {
+	// With elements arriving at 25ms and 50ms and a 10ms interval, heartbeats land at 10 and 20ms, then 35 and 45ms
+	// once the interval restarts after the first element.
+	synctest.Test(t, func(t *testing.T) {
+		slow := func(yield func(int) bool) {
+			for i := 1; i <= 2; i++ {
+				time.Sleep(25 * time.Millisecond)
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		var got []int
+		for v := range seq.Heartbeat(t.Context(), slow, 10*time.Millisecond, 0) {
+			got = append(got, v)
+		}
+		if want := []int{0, 0, 1, 0, 0, 2}; !slices.Equal(got, want) {
+			t.Errorf("Heartbeat yielded %v, want %v", got, want)
+		}
+	})
+}
+
+func TestHeartbeatCancelUnblocks(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		go func() {
+			time.Sleep(25 * time.Millisecond)
+			cancel()
+		}()
+		var beats int
+		for range seq.Heartbeat(ctx, seq.FromChanCtx(ctx, make(chan int)), 10*time.Millisecond, 0) {
+			beats++
+		}
+		if beats != 2 {
+			t.Errorf("Heartbeat yielded %d heartbeats before cancellation, want 2", beats)
+		}
+	})
+}
+
+func TestSingleUseStrictMode(t *testing.T) {
+	s := seq.SingleUse(seq.With(1, 2, 3), "ints")
+	for range s {
+	}
+	// strict mode is off by default, so a second iteration is allowed
+	if n := seq.Count(s); n != 3 {
+		t.Errorf("SingleUse without strict mode yielded %d values, want 3", n)
+	}
+
+	seq.SetStrict(true)
+	defer seq.SetStrict(false)
+	mustPanic(t, "SingleUse reused in strict mode", func() { seq.Count(s) })
+
+	// a fresh sequence in strict mode panics on its second iteration only, and reports the first use's stack
+	s = seq.SingleUse(seq.With(1), "one")
+	seq.Count(s)
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, `"one"`) || !strings.Contains(msg, "TestSingleUseStrictMode") {
+			t.Errorf("unexpected panic message: %q", msg)
+		}
+	}()
+	seq.Count(s)
+	t.Error("SingleUse did not panic on second iteration in strict mode")
+}
+
+func TestReplayPanicsOnNonPositiveSpeed(t *testing.T) {
+	type event = seq.KV[time.Time, int]
+	mustPanic(t, "Replay speed 0", func() { seq.Replay(seq.WithKV[time.Time, int](), 0) })
+	mustPanic(t, "Replay speed -1", func() { seq.Replay(seq.WithKV(event{K: time.Now(), V: 1}), -1) })
+}
+
+func TestReplayTiming(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		type event = seq.KV[time.Time, int]
+		recorded := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		events := seq.WithKV(
+			event{K: recorded, V: 0},
+			event{K: recorded.Add(40 * time.Millisecond), V: 20},
+			event{K: recorded.Add(30 * time.Millisecond), V: 20}, // out of order: due immediately
+			event{K: recorded.Add(100 * time.Millisecond), V: 50},
+		)
+		start := time.Now()
+		for v := range seq.Replay(events, 2) {
+			if elapsed := time.Since(start); elapsed != time.Duration(v)*time.Millisecond {
+				t.Errorf("Replay yielded %d after %v, want %dms", v, elapsed, v)
+			}
+		}
+	})
+}
+
+func TestToChanCloseStopReleasesProducer(t *testing.T) {
+	// Regression guard for the ToChan leak ToChanClose exists to avoid: after stop, the producing goroutine must exit
+	// even though nobody receives from the channel again. synctest.Test fails if it stays blocked.
+	synctest.Test(t, func(t *testing.T) {
+		ch, stop := seq.ToChanClose(seq.Repeat(100, 1))
+		<-ch
+		stop()
+		stop()
+		synctest.Wait()
+	})
+}
+
+func TestRangeStepPanicsOnZeroStep(t *testing.T) {
+	mustPanic(t, "RangeStep step 0", func() { seq.RangeStep(0, 10, 0) })
+}
+
+func TestRangeStepDoesNotOverflow(t *testing.T) {
+	// Every int8 and uint8 value must be produced exactly once when ranging across the whole type, without wrapping
+	// around into an endless loop.
+	withTimeout(t, 5*time.Second, func() {
+		if n := seq.Count(seq.RangeStep[int8](-128, 127, 1)); n != 255 {
+			t.Errorf("RangeStep over int8 yielded %d values, want 255", n)
+		}
+		if n := seq.Count(seq.RangeStep[int8](127, -128, -1)); n != 255 {
+			t.Errorf("descending RangeStep over int8 yielded %d values, want 255", n)
+		}
+		if n := seq.Count(seq.RangeStep[uint8](0, 255, 100)); n != 3 {
+			t.Errorf("RangeStep over uint8 by 100 yielded %d values, want 3", n)
+		}
+	})
+}
+
+func TestForEachSeqPanicsOnNonPositiveParallel(t *testing.T) {
+	outer := seq.Chunk(seq.With(1, 2, 3), 1)
+	noop := func(context.Context, iter.Seq[int]) error { return nil }
+	mustPanic(t, "ForEachSeq parallel 0", func() { _ = seq.ForEachSeq(t.Context(), outer, 0, noop) })
+	mustPanic(t, "ForEachSeq parallel -1", func() { _ = seq.ForEachSeq(t.Context(), outer, -1, noop) })
+}
+
+func TestForEachSeqBoundsConcurrency(t *testing.T) {
+	const parallel = 4
+	var running, peak atomic.Int32
+	var calls atomic.Int32
+	err := seq.ForEachSeq(t.Context(), seq.Chunk(seq.Range(0, 1000), 10), parallel, func(ctx context.Context, inner iter.Seq[int]) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		calls.Add(1)
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachSeq returned %v", err)
+	}
+	if calls.Load() != 100 {
+		t.Errorf("ForEachSeq made %d calls, want 100", calls.Load())
+	}
+	if peak.Load() > parallel {
+		t.Errorf("ForEachSeq ran %d calls concurrently, want at most %d", peak.Load(), parallel)
+	}
+}
+
+func TestForEachSeqErrorCancelsOthers(t *testing.T) {
+	errBoom := errors.New("boom")
+	var started atomic.Int32
+	err := seq.ForEachSeq(t.Context(), seq.Chunk(seq.Range(0, 1000), 1), 3, func(ctx context.Context, inner iter.Seq[int]) error {
+		started.Add(1)
+		if v, _ := seq.At(inner, 0); v == 2 {
+			return errBoom
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("ForEachSeq returned %v, want %v", err, errBoom)
+	}
+	if n := started.Load(); n > 8 {
+		t.Errorf("ForEachSeq kept starting calls after an error: %d started", n)
+	}
+}
+
+func TestForEachSeqParentCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var calls atomic.Int32
+	err := seq.ForEachSeq(ctx, seq.Chunk(seq.Range(0, 1000), 1), 1, func(ctx context.Context, inner iter.Seq[int]) error {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ForEachSeq returned %v, want %v", err, context.Canceled)
+	}
+	if n := calls.Load(); n > 4 {
+		t.Errorf("ForEachSeq made %d calls after cancellation, want at most 4", n)
+	}
+}
+
+func TestForEachSeqCancelAfterLastCall(t *testing.T) {
+	// Canceling the parent only once every inner sequence has been handed to a call must not turn success into an error.
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	err := seq.ForEachSeq(ctx, seq.Chunk(seq.Range(0, 10), 2), 2, func(_ context.Context, inner iter.Seq[int]) error {
+		for v := range inner {
+			if v == 9 {
+				cancel()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ForEachSeq returned %v after every call succeeded, want nil", err)
+	}
+}
+
+var registerUpperSource sync.Once
+
+func TestSourceRegister(t *testing.T) {
+	registerUpperSource.Do(func() {
+		source.Register("upper", func(ctx context.Context, spec *url.URL) (iter.Seq2[string, error], error) {
+			return seq.MapKV(seq.WithKV(seq.KV[string, error]{K: strings.ToUpper(spec.Opaque)}), func(s string, err error) (string, error) {
+				return s, err
+			}), nil
+		})
+	})
+	lines, err := source.Open(t.Context(), "upper:hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Collect(seq.IterK(lines)); !slices.Equal(got, []string{"HELLO"}) {
+		t.Errorf("Open(upper:hello) yielded %v, want [HELLO]", got)
+	}
+
+	noop := func(context.Context, *url.URL) (iter.Seq2[string, error], error) { return nil, nil }
+	mustPanic(t, "source.Register duplicate", func() { source.Register("upper", noop) })
+	mustPanic(t, "source.Register default scheme", func() { source.Register("file", noop) })
+	mustPanic(t, "source.Register empty scheme", func() { source.Register("", noop) })
+	mustPanic(t, "source.Register nil func", func() { source.Register("nilfunc", nil) })
+}
+
+func TestSourceOpenErrors(t *testing.T) {
+	for _, spec := range []string{"range:5", "range:a-b", "range:-1-3", "file://", "unknown:x", "%zz"} {
+		if _, err := source.Open(t.Context(), spec); err == nil {
+			t.Errorf("Open(%q) returned no error", spec)
+		}
+	}
+
+	lines, err := source.Open(t.Context(), "file:///does/not/exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range lines {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("missing file yielded %v, want %v", err, fs.ErrNotExist)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	nums, err := source.Open(ctx, "range:1-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, err := range nums {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("canceled range yielded %q, %v; want %v", line, err, context.Canceled)
+		}
+	}
+}
+
+func TestBroadcasterConcurrentSubscribers(t *testing.T) {
+	// Subscribers join and leave while Run delivers; every blocking subscriber that stays until the end must see an
+	// increasing run of elements, and unsubscribing must never wedge Run.
+	synctest.Test(t, func(t *testing.T) {
+		const n = 1000
+		b := seq.NewBroadcaster(seq.Range(0, n))
+		var wg sync.WaitGroup
+		for i := range 8 {
+			s, unsubscribe := b.Subscribe(i, seq.BlockSlow)
+			wg.Go(func() {
+				prev := -1
+				for v := range s {
+					if v <= prev {
+						t.Errorf("subscriber %d: got %d after %d", i, v, prev)
+					}
+					prev = v
+					if i%2 == 0 && v == n/2 {
+						unsubscribe()
+					}
+				}
+			})
+		}
+		for range 4 {
+			s, _ := b.Subscribe(0, seq.DropSlow)
+			wg.Go(func() {
+				for v := range s {
+					if v > n/4 {
+						break
+					}
+				}
+			})
+		}
+		if err := b.Run(t.Context()); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+		wg.Wait()
+		synctest.Wait()
+	})
+}
+
+func TestBroadcasterCancelUnblocksRun(t *testing.T) {
+	// A blocking subscriber that never reads must not keep Run from returning once ctx is canceled.
+	synctest.Test(t, func(t *testing.T) {
+		b := seq.NewBroadcaster(seq.Iterate(0, func(v int) int { return v + 1 }))
+		b.Subscribe(0, seq.BlockSlow)
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error)
+		go func() { done <- b.Run(ctx) }()
+		synctest.Wait()
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestRetrySeqPanicsOnNonPositiveAttempts(t *testing.T) {
+	open := func() (iter.Seq2[int, error], error) { return nil, nil }
+	mustPanic(t, "RetrySeq attempts 0", func() { seq.RetrySeq(open, 0, nil, nil) })
+}
+
+func TestRetrySeqBackoffAndSkip(t *testing.T) {
+	// Without a resume hook the reopened source starts over, so RetrySeq must skip what it already delivered, and it
+	// must sleep each backoff delay between attempts.
+	synctest.Test(t, func(t *testing.T) {
+		errFlaky := errors.New("flaky")
+		opens := 0
+		open := func() (iter.Seq2[int, error], error) {
+			opens++
+			fail := opens
+			return func(yield func(int, error) bool) {
+				for i := range 5 {
+					if i == fail {
+						yield(0, errFlaky)
+						return
+					}
+					if !yield(i, nil) {
+						return
+					}
+				}
+			}, nil
+		}
+		start := time.Now()
+		var got []int
+		for v, err := range seq.RetrySeq(open, 10, seq.With(time.Second, 2*time.Second), nil) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got = append(got, v)
+		}
+		if want := []int{0, 1, 2, 3, 4}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if opens != 5 {
+			t.Errorf("opened %d times, want 5", opens)
+		}
+		// two delays from backoff, then none once it is exhausted
+		if elapsed := time.Since(start); elapsed != 3*time.Second {
+			t.Errorf("elapsed %v, want 3s", elapsed)
+		}
+	})
+}
+
+func TestFairMergeKVCancel(t *testing.T) {
+	// Merging endless streams must stop once ctx is canceled, and a consumer stopping early must release every source.
+	ctx, cancel := context.WithCancel(t.Context())
+	endless := seq.IterKV(seq.Iterate(0, func(v int) int { return v + 1 }), func(v int) int { return v % 3 })
+	withTimeout(t, 5*time.Second, func() {
+		n := 0
+		for range seq.FairMergeKV(ctx, nil, endless, endless) {
+			if n++; n == 100 {
+				cancel()
+			}
+		}
+	})
+	var n int
+	for range seq.FairMergeKV(t.Context(), map[int]int{0: 5}, endless, endless) {
+		if n++; n == 10 {
+			break
+		}
+	}
+}
+
+func TestWireRecordPlaybackErrors(t *testing.T) {
+	dir := t.TempDir()
+	fixture := dir + "/fixture"
+
+	// stopping early still leaves a complete fixture of what was consumed
+	for v, err := range wire.Record(seq.With("a", "b", "c"), fixture, wire.JSONCodec[string]{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v == "b" {
+			break
+		}
+	}
+	var got []string
+	for v, err := range wire.Playback(fixture, wire.JSONCodec[string]{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if want := []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("Playback = %v, want %v", got, want)
+	}
+
+	// decoding with the wrong codec yields an error and ends
+	var errs int
+	for _, err := range wire.Playback(fixture, wire.JSONCodec[int]{}) {
+		if err != nil {
+			errs++
+		}
+	}
+	if errs != 1 {
+		t.Errorf("got %d errors decoding with the wrong codec, want 1", errs)
+	}
+
+	for _, err := range wire.Playback(dir+"/missing", wire.JSONCodec[string]{}) {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Playback of a missing file: got %v, want fs.ErrNotExist", err)
+		}
+	}
+	for _, err := range wire.Record(seq.With(1), dir+"/no/such/dir", wire.JSONCodec[int]{}) {
+		if err == nil {
+			t.Error("Record into a missing directory: got nil error")
+		}
+	}
+
+	// a length prefix far beyond MaxFrameSize must fail cleanly rather than panic or allocate it
+	corrupt := dir + "/corrupt"
+	if err := os.WriteFile(corrupt, binary.AppendUvarint(nil, math.MaxUint64), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range wire.Playback(corrupt, wire.JSONCodec[string]{}) {
+		if !errors.Is(err, wire.ErrFrameTooLarge) {
+			t.Errorf("Playback of a corrupt fixture: got %v, want wire.ErrFrameTooLarge", err)
+		}
+	}
+}
+
+func TestWireRecordFlushErrorAfterEarlyStop(t *testing.T) {
+	// Regression: a failed flush after the consumer stopped early was yielded anyway, panicking the range loop.
+	// Writes to /dev/full fail with ENOSPC, but only once the buffered writer flushes.
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	for _, err := range wire.Record(seq.With(1, 2, 3), "/dev/full", wire.JSONCodec[int]{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+}
+
+func TestWritePartitionedRotationAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	line := func(s string) []byte { return []byte(s + "\n") }
+	tenant := func(s string) string { return s[:1] }
+
+	// each line is 4 bytes, so a 10 byte limit fits two lines per file
+	counts, err := seq.WritePartitioned(seq.With("a01", "b01", "a02", "a03", "a04", "a05"), dir, tenant, line, seq.MaxFileSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["a"] != 5 || counts["b"] != 1 {
+		t.Errorf("counts = %v, want a:5 b:1", counts)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "a", "part-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("got %d files for partition a, want 3: %v", len(files), files)
+	}
+	last, err := os.ReadFile(filepath.Join(dir, "a", "part-00002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(last) != "a05\n" {
+		t.Errorf("last file = %q, want %q", last, "a05\n")
+	}
+
+	// a partition escaping dir is rejected, and the counts cover what was written before it
+	counts, err = seq.WritePartitioned(seq.With("ok", "../escape", "later"), t.TempDir(), func(s string) string { return s }, line)
+	if err == nil {
+		t.Error("expected an error for a non-local partition")
+	}
+	if want := map[string]int{"ok": 1}; !maps.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+}
+
+func TestWireServeDialEarlyStopAndShutdown(t *testing.T) {
+	// A client stopping early must cancel the server's endless sequence, and closing the listener must make Serve
+	// return even while a client is connected.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var canceled atomic.Int32
+	served := make(chan error)
+	go func() {
+		served <- wire.Serve(l, func(ctx context.Context) iter.Seq[int] {
+			context.AfterFunc(ctx, func() { canceled.Add(1) })
+			return seq.Iterate(0, func(v int) int { return v + 1 })
+		}, wire.JSONCodec[int]{})
+	}()
+
+	withTimeout(t, 10*time.Second, func() {
+		for v, err := range wire.Dial(context.Background(), "tcp", l.Addr().String(), wire.JSONCodec[int]{}) {
+			if err != nil || v == 100 {
+				break
+			}
+		}
+		for canceled.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		// a client still streaming is disconnected by shutdown
+		connected := make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			first := true
+			for _, err := range wire.Dial(context.Background(), "tcp", l.Addr().String(), wire.JSONCodec[int]{}) {
+				if err != nil {
+					errs <- err
+					return
+				}
+				if first {
+					first = false
+					close(connected)
+				}
+			}
+			errs <- nil
+		}()
+		<-connected
+		l.Close()
+		if err := <-served; err != nil {
+			t.Errorf("Serve = %v, want nil", err)
+		}
+		if err := <-errs; err == nil {
+			t.Error("Dial after shutdown: got nil error, want a truncated stream error")
+		}
+	})
+}
+
+func TestWireDialErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	for _, err := range wire.Dial(t.Context(), "tcp", addr, wire.JSONCodec[int]{}) {
+		if err == nil {
+			t.Error("Dial to a closed port: got nil error")
+		}
+	}
+
+	// encoding errors on the server are reported to the client
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go wire.Serve(l, func(context.Context) iter.Seq[func()] { return seq.With(func() {}) }, wire.JSONCodec[func()]{})
+	var got error
+	for _, err := range wire.Dial(t.Context(), "tcp", l.Addr().String(), wire.JSONCodec[func()]{}) {
+		got = err
+	}
+	if got == nil || !strings.Contains(got.Error(), "wire: server:") {
+		t.Errorf("got %v, want a server error", got)
+	}
+}
+
+func TestWireDialRejectsHugeFrame(t *testing.T) {
+	// A server announcing an absurd frame length must get an error, not a panic or a huge allocation in the client.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(binary.AppendUvarint([]byte{'v'}, math.MaxUint64))
+		io.Copy(io.Discard, conn) // hold the connection open until the client closes it
+	}()
+	var errs []error
+	for _, err := range wire.Dial(t.Context(), "tcp", l.Addr().String(), wire.BytesCodec{}) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], wire.ErrFrameTooLarge) {
+		t.Errorf("Dial from a server sending a huge frame yielded %v, want one wire.ErrFrameTooLarge", errs)
+	}
+}
+
+func TestWatchdogPanicsOnNonPositiveGap(t *testing.T) {
+	mustPanic(t, "Watchdog gap 0", func() { seq.Watchdog(seq.With(1), 0, func(time.Duration, int) {}) })
+}
+
+func TestWatchdogTiming(t *testing.T) {
+	// Stalls are reported every maxGap while the producer waits, and time the consumer spends is not counted.
+	synctest.Test(t, func(t *testing.T) {
+		producer := func(yield func(int) bool) {
+			yield(0)
+			time.Sleep(35 * time.Second)
+			yield(1)
+		}
+		var mu sync.Mutex
+		var stalls []time.Duration
+		onStall := func(d time.Duration, consumed int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if consumed != 1 {
+				t.Errorf("consumed = %d, want 1", consumed)
+			}
+			stalls = append(stalls, d)
+		}
+		for range seq.Watchdog(producer, 10*time.Second, onStall) {
+			// a slow consumer must not trigger the watchdog
+			time.Sleep(time.Minute)
+		}
+		synctest.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		if want := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second}; !slices.Equal(stalls, want) {
+			t.Errorf("stalls = %v, want %v", stalls, want)
+		}
+	})
+}
+
+func TestPercentilePanicsOutOfRange(t *testing.T) {
+	for _, p := range []float64{-1, 100.5, math.NaN()} {
+		mustPanic(t, fmt.Sprint("Percentile p ", p), func() { seq.Percentile(seq.With(1, 2), p) })
+	}
+}
+
+func TestAssertPanicsWithoutHandler(t *testing.T) {
+	mustPanic(t, "Assert without handler", func() {
+		for range seq.Assert(seq.With(1, -1), func(v int) bool { return v > 0 }, nil) {
+		}
+	})
+}
+
+func TestWeightedReservoirProportions(t *testing.T) {
+	// With k of 1, each key should be chosen in proportion to its weight.
+	src := rand.New(rand.NewPCG(7, 11))
+	weights := seq.WithKV(seq.KV[string, float64]{K: "a", V: 1}, seq.KV[string, float64]{K: "b", V: 3})
+	const trials = 20000
+	counts := make(map[string]int)
+	for range trials {
+		for _, k := range seq.WeightedReservoir(weights, 1, src) {
+			counts[k]++
+		}
+	}
+	if got := float64(counts["b"]) / trials; math.Abs(got-0.75) > 0.02 {
+		t.Errorf("b chosen %.3f of the time, want about 0.75", got)
+	}
+}
+
+func TestHeadReleasesSource(t *testing.T) {
+	// Iterating the rest, or breaking out of it at once, must release the pulled source.
+	synctest.Test(t, func(t *testing.T) {
+		var released atomic.Int32
+		src := func(yield func(int) bool) {
+			defer released.Add(1)
+			for i := range 3 {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		_, rest, _ := seq.Head(iter.Seq[int](src))
+		for range rest {
+			break
+		}
+		_, rest, _ = seq.Head(iter.Seq[int](src))
+		if got := slices.Collect(rest); !slices.Equal(got, []int{0, 1, 2}) {
+			t.Errorf("rest = %v, want [0 1 2]", got)
+		}
+		synctest.Wait()
+		if released.Load() != 2 {
+			t.Errorf("source released %d times, want 2", released.Load())
+		}
+	})
+}
+
+func TestSessionsPanicsOnNegativeGap(t *testing.T) {
+	mustPanic(t, "Sessions gap -1", func() { seq.Sessions(seq.With(1), func(int) time.Time { return time.Time{} }, -1) })
+}
+
+func TestToChansByKeyPanicsOnNonPositiveN(t *testing.T) {
+	mustPanic(t, "ToChansByKey n 0", func() { seq.ToChansByKey(t.Context(), seq.WithKV[int, int](), 0) })
+}
+
+func TestToChansByKeyRoutingAndCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		const keys = 50
+		src := seq.IterKV(seq.Range(0, 5000), func(v int) int { return v % keys })
+		chs := seq.ToChansByKey(t.Context(), src, 4)
+		var mu sync.Mutex
+		owner := make(map[int]int)
+		var wg sync.WaitGroup
+		for i, ch := range chs {
+			wg.Go(func() {
+				last := make(map[int]int)
+				for kv := range ch {
+					if prev, ok := last[kv.K]; ok && kv.V <= prev {
+						t.Errorf("key %d: %d after %d", kv.K, kv.V, prev)
+					}
+					last[kv.K] = kv.V
+					mu.Lock()
+					if o, ok := owner[kv.K]; ok && o != i {
+						t.Errorf("key %d seen on channels %d and %d", kv.K, o, i)
+					}
+					owner[kv.K] = i
+					mu.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+		if len(owner) != keys {
+			t.Errorf("saw %d keys, want %d", len(owner), keys)
+		}
+
+		// canceling with nobody reading must still close every channel and release the goroutine
+		ctx, cancel := context.WithCancel(t.Context())
+		chs = seq.ToChansByKey(ctx, seq.IterKV(seq.Iterate(0, func(v int) int { return v + 1 }), func(v int) int { return v }), 2)
+		cancel()
+		for _, ch := range chs {
+			for range ch {
+			}
+		}
+		synctest.Wait()
+	})
+}
+
+func TestSeqerrMustPanicsWithWrappedError(t *testing.T) {
+	errBoom := errors.New("boom")
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, errBoom) {
+			t.Errorf("Must panicked with %v, want an error wrapping %v", err, errBoom)
+		}
+	}()
+	for range seqerr.Must(seq.WithKV(seq.KV[int, error]{K: 1}, seq.KV[int, error]{V: errBoom})) {
+	}
+	t.Error("Must did not panic")
+}
+
+func TestSeqerrChunkPanicsOnNonPositiveSize(t *testing.T) {
+	var src seqerr.SeqE[int] = func(func(int, error) bool) {}
+	mustPanic(t, "seqerr.Chunk size=0", func() { seqerr.Chunk(src, 0) })
+}
+
+func TestPipelineMiddleStageErrorTearsDown(t *testing.T) {
+	// A failing middle stage must stop the endless source upstream and the stage downstream, and Run must not return
+	// before they have exited; synctest.Test fails if any goroutine is left blocked when the bubble ends.
+	synctest.Test(t, func(t *testing.T) {
+		errBoom := errors.New("boom")
+		var sourceDone, lastDone atomic.Bool
+		src := func(yield func(int) bool) {
+			defer sourceDone.Store(true)
+			for i := 0; yield(i); i++ {
+			}
+		}
+		fail := pipeline.Map(func(_ context.Context, v int) (int, error) {
+			if v == 10 {
+				return 0, errBoom
+			}
+			return v, nil
+		})
+		last := pipeline.Stage[int](func(ctx context.Context, in iter.Seq[int], yield func(int) bool) error {
+			defer lastDone.Store(true)
+			for v := range in {
+				if !yield(v) {
+					break
+				}
+			}
+			return nil
+		})
+		var got int
+		err := pipeline.New(iter.Seq[int](src)).Then(fail).Then(last).Run(context.Background(), func(int) error {
+			got++
+			return nil
+		})
+		if !errors.Is(err, errBoom) {
+			t.Errorf("Run returned %v, want %v", err, errBoom)
+		}
+		if got > 10 { // elements still in flight when the stage fails may or may not reach the sink
+			t.Errorf("sink received %d elements, want at most 10", got)
+		}
+		if !sourceDone.Load() || !lastDone.Load() {
+			t.Errorf("Run returned before teardown: source done %v, last stage done %v", sourceDone.Load(), lastDone.Load())
+		}
+	})
+}
+
+func TestPipelineSinkErrorAndCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		naturals := func(yield func(int) bool) {
+			for i := 0; yield(i); i++ {
+			}
+		}
+		pass := pipeline.Filter(func(context.Context, int) (bool, error) { return true, nil })
+
+		errStop := errors.New("stop")
+		err := pipeline.New(iter.Seq[int](naturals)).Then(pass).Run(context.Background(), func(v int) error {
+			if v == 3 {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("Run with failing sink returned %v, want %v", err, errStop)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err = pipeline.New(iter.Seq[int](naturals)).Then(pass).Run(ctx, func(int) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run with expiring context returned %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestParallelFilterPanicsOnNonPositiveWorkers(t *testing.T) {
+	pred := func(context.Context, int) bool { return true }
+	mustPanic(t, "ParallelFilter workers=0", func() { seq.ParallelFilter(context.Background(), seq.With(1), 0, pred) })
+}
+
+func TestParallelFilterOrderAndConcurrency(t *testing.T) {
+	// Predicates finish out of order, but the output must keep sequence order and never run more than workers at once.
+	synctest.Test(t, func(t *testing.T) {
+		const workers = 4
+		var running, peak atomic.Int32
+		pred := func(_ context.Context, v int) bool {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Duration(100-v) * time.Millisecond)
+			running.Add(-1)
+			return v%3 != 0
+		}
+		got := slices.Collect(seq.ParallelFilter(context.Background(), seq.Range(0, 100), workers, pred))
+		want := slices.Collect(seq.Filter(seq.Range(0, 100), func(v int) bool { return v%3 != 0 }))
+		if !slices.Equal(got, want) {
+			t.Errorf("ParallelFilter = %v, want %v", got, want)
+		}
+		if p := peak.Load(); p > workers {
+			t.Errorf("ParallelFilter ran %d predicates at once, want at most %d", p, workers)
+		}
+	})
+}
+
+func TestParallelFilterReadAhead(t *testing.T) {
+	// While the consumer holds on to its first element, no more than workers further elements may be read.
+	synctest.Test(t, func(t *testing.T) {
+		var pulled atomic.Int32
+		src := func(yield func(int) bool) {
+			for i := 0; ; i++ {
+				pulled.Add(1)
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		const workers = 3
+		keep := func(context.Context, int) bool { return true }
+		for range seq.ParallelFilter(context.Background(), iter.Seq[int](src), workers, keep) {
+			synctest.Wait()
+			if n := pulled.Load() - 1; n > workers {
+				t.Errorf("ParallelFilter read %d elements ahead of the consumer, want at most %d", n, workers)
+			}
+			break
+		}
+	})
+}
+
+func TestParallelFilterEarlyStopAndCancel(t *testing.T) {
+	// Stopping early, or canceling the context, must cancel the predicates' context and leave no goroutines behind;
+	// synctest.Test fails if any are still blocked when the bubble ends.
+	synctest.Test(t, func(t *testing.T) {
+		naturals := seq.Iterate(0, func(v int) int { return v + 1 })
+		pred := func(ctx context.Context, v int) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Millisecond):
+				return true
+			}
+		}
+		if got := slices.Collect(seq.Take(seq.ParallelFilter(context.Background(), naturals, 8, pred), 5)); !slices.Equal(got, []int{0, 1, 2, 3, 4}) {
+			t.Errorf("ParallelFilter then Take(5) = %v, want [0 1 2 3 4]", got)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		var n int
+		for range seq.ParallelFilter(ctx, naturals, 8, pred) {
+			n++
+		}
+		if n == 0 {
+			t.Error("ParallelFilter with expiring context yielded nothing before the deadline")
+		}
+	})
+}
+
+func TestParallelForEachPanicsOnNonPositiveWorkers(t *testing.T) {
+	fn := func(context.Context, int) error { return nil }
+	mustPanic(t, "ParallelForEach workers=0", func() { _ = seq.ParallelForEach(context.Background(), seq.With(1), 0, fn) })
+	mustPanic(t, "ParallelForEachAll workers=0", func() { _ = seq.ParallelForEachAll(context.Background(), seq.With(1), 0, fn) })
+}
+
+func TestParallelForEachFailFastCancels(t *testing.T) {
+	// The first error must cancel the calls in flight and stop an endless sequence, and ParallelForEach must not
+	// return before the canceled calls have.
+	synctest.Test(t, func(t *testing.T) {
+		errBoom := errors.New("boom")
+		var canceled, running atomic.Int32
+		naturals := seq.Iterate(0, func(v int) int { return v + 1 })
+		err := seq.ParallelForEach(context.Background(), naturals, 4, func(ctx context.Context, v int) error {
+			running.Add(1)
+			defer running.Add(-1)
+			if v == 3 {
+				return errBoom
+			}
+			<-ctx.Done() // only the error from the fourth call can release the first three
+			canceled.Add(1)
+			return ctx.Err()
+		})
+		if !errors.Is(err, errBoom) {
+			t.Errorf("ParallelForEach returned %v, want %v", err, errBoom)
+		}
+		if canceled.Load() != 3 {
+			t.Errorf("ParallelForEach canceled %d calls in flight, want 3", canceled.Load())
+		}
+		if running.Load() != 0 {
+			t.Errorf("ParallelForEach returned with %d calls still running", running.Load())
+		}
+	})
+}
+
+func TestParallelForEachAllRunsEveryCall(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var calls atomic.Int32
+		err := seq.ParallelForEachAll(context.Background(), seq.Range(0, 50), 4, func(_ context.Context, v int) error {
+			calls.Add(1)
+			time.Sleep(time.Millisecond)
+			if v%10 == 0 {
+				return fmt.Errorf("failed %d", v)
+			}
+			return nil
+		})
+		if calls.Load() != 50 {
+			t.Errorf("ParallelForEachAll made %d calls, want 50", calls.Load())
+		}
+		var joined interface{ Unwrap() []error }
+		if !errors.As(err, &joined) || len(joined.Unwrap()) != 5 {
+			t.Errorf("ParallelForEachAll returned %v, want 5 joined errors", err)
+		}
+	})
+}
+
+func TestFanOutPanicsOnNonPositiveN(t *testing.T) {
+	mustPanic(t, "FanOut n=0", func() { seq.FanOut(context.Background(), seq.With(1), 0) })
+	mustPanic(t, "FanOutBy n=0", func() { seq.FanOutBy(context.Background(), seq.With(1), 0, func(v int) int { return v }) })
+}
+
+func TestFanOutEarlyStopReroutes(t *testing.T) {
+	// A consumer that stops early must not block the others: its share goes to the remaining consumers, and once
+	// every consumer has stopped the goroutine reading an endless source must exit (synctest.Test fails otherwise).
+	synctest.Test(t, func(t *testing.T) {
+		outs := seq.FanOut(context.Background(), seq.Range(0, 100), 3)
+		var wg sync.WaitGroup
+		var total atomic.Int32
+		for i, out := range outs {
+			wg.Go(func() {
+				n := 0
+				for range out {
+					total.Add(1)
+					if n++; i == 0 && n == 5 {
+						return
+					}
+				}
+			})
+		}
+		wg.Wait()
+		if total.Load() != 100 {
+			t.Errorf("FanOut consumers received %d elements in total, want 100", total.Load())
+		}
+
+		naturals := seq.Iterate(0, func(v int) int { return v + 1 })
+		for _, out := range seq.FanOutBy(context.Background(), naturals, 4, func(v int) int { return v }) {
+			wg.Go(func() {
+				for range seq.Take(out, 10) {
+				}
+			})
+		}
+		wg.Wait()
+	})
+}
+
+func TestFanOutCancel(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		naturals := seq.Iterate(0, func(v int) int { return v + 1 })
+		outs := seq.FanOut(ctx, naturals, 2)
+		var wg sync.WaitGroup
+		var total atomic.Int32
+		for _, out := range outs {
+			wg.Go(func() {
+				for range out {
+					if total.Add(1) == 10 {
+						cancel()
+					}
+				}
+			})
+		}
+		wg.Wait() // hangs, and synctest.Test reports the deadlock, if canceling doesn't end the sequences
+	})
+}
+
+func TestFanInOrderPerSourceAndStop(t *testing.T) {
+	// Each source's elements must arrive in order; stopping early, or canceling, must leave no goroutine blocked
+	// sending (synctest.Test fails if one is).
+	synctest.Test(t, func(t *testing.T) {
+		srcs := make([]iter.Seq[int], 4)
+		for i := range srcs {
+			srcs[i] = seq.Range(i*1000, i*1000+500)
+		}
+		last := map[int]int{}
+		var n int
+		for v := range seq.FanIn(context.Background(), srcs...) {
+			n++
+			if prev, ok := last[v/1000]; ok && v != prev+1 {
+				t.Fatalf("FanIn yielded %d after %d from the same source", v, prev)
+			}
+			last[v/1000] = v
+		}
+		if n != 2000 {
+			t.Errorf("FanIn yielded %d elements, want 2000", n)
+		}
+
+		naturals := seq.Iterate(0, func(v int) int { return v + 1 })
+		for range seq.Take(seq.FanIn(context.Background(), naturals, naturals, naturals), 10) {
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		n = 0
+		for range seq.FanIn(ctx, naturals, naturals) {
+			if n++; n == 10 {
+				cancel()
+			}
+		}
+		synctest.Wait()
+	})
+}
+
+func TestTeePanicsOnNonPositiveN(t *testing.T) {
+	mustPanic(t, "Tee n=0", func() { seq.Tee(seq.With(1), 0) })
+}
+
+func TestTeeConcurrentConsumersAndRelease(t *testing.T) {
+	// Consumers on different goroutines must each see every element, one stopping early must not disturb the others,
+	// and the source must be released once they have all stopped.
+	var released atomic.Bool
+	src := func(yield func(int) bool) {
+		defer released.Store(true)
+		for i := range 10000 {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	copies := seq.Tee(iter.Seq[int](src), 4)
+	sums := make([]int, len(copies))
+	var wg sync.WaitGroup
+	for i, c := range copies {
+		wg.Go(func() {
+			limit := 10000
+			if i == 0 {
+				limit = 100
+			}
+			for v := range seq.Take(c, limit) {
+				sums[i] += v
+			}
+		})
+	}
+	wg.Wait()
+	if want := 99 * 100 / 2; sums[0] != want {
+		t.Errorf("Tee copy 0 summed %d, want %d", sums[0], want)
+	}
+	for i, sum := range sums[1:] {
+		if want := 9999 * 10000 / 2; sum != want {
+			t.Errorf("Tee copy %d summed %d, want %d", i+1, sum, want)
+		}
+	}
+	if !released.Load() {
+		t.Error("Tee did not release the source after every copy finished")
+	}
+
+	released.Store(false)
+	copies = seq.Tee(iter.Seq[int](src), 2)
+	for range seq.Take(copies[0], 1) {
+	}
+	for range seq.Take(copies[1], 1) {
+	}
+	if !released.Load() {
+		t.Error("Tee did not release the source after every copy stopped early")
+	}
+}
+
+func TestBroadcastPanics(t *testing.T) {
+	mustPanic(t, "Broadcast subscribers=0", func() { seq.Broadcast(context.Background(), seq.With(1), 0, 0) })
+	mustPanic(t, "Broadcast buf=-1", func() { seq.Broadcast(context.Background(), seq.With(1), 1, -1) })
+}
+
+func TestBroadcastCancelReleasesProducer(t *testing.T) {
+	// A subscriber that stops reading blocks the producer; canceling the context must close every channel anyway.
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		subs := seq.Broadcast(ctx, seq.Iterate(0, func(v int) int { return v + 1 }), 2, 4)
+		// subs[1] is never read, so the producer stalls once its buffer of 4 is full
+		for range seq.Take(seq.FromChan(subs[0]), 5) {
+		}
+		synctest.Wait()
+		cancel()
+		for _, sub := range subs {
+			for range sub {
+			}
+		}
+	})
+}
+
+func TestBufferPanicsOnNonPositiveN(t *testing.T) {
+	mustPanic(t, "Buffer n=0", func() { seq.Buffer(seq.With(1), 0) })
+}
+
+func TestBufferOverlapsAndStops(t *testing.T) {
+	// With a 10ms producer and a 10ms consumer, buffering lets them overlap, so 10 elements take well under the 200ms
+	// they would take in turn; the exact figure depends on which of the two wakes first when their sleeps end together.
+	// Stopping early must stop the producer before iteration returns.
+	synctest.Test(t, func(t *testing.T) {
+		var produced atomic.Int32
+		slow := func(yield func(int) bool) {
+			for i := 0; ; i++ {
+				time.Sleep(10 * time.Millisecond)
+				produced.Add(1)
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		start := time.Now()
+		for range seq.Take(seq.Buffer(iter.Seq[int](slow), 4), 10) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("Buffer took %v to deliver 10 elements and stop, want at most 150ms", elapsed)
+		}
+		n := produced.Load()
+		time.Sleep(time.Second)
+		if produced.Load() != n {
+			t.Errorf("Buffer kept producing after iteration returned: %d then %d", n, produced.Load())
+		}
+	})
+}
+
+func TestSplitPanicsOnNonPositiveN(t *testing.T) {
+	mustPanic(t, "Split n=0", func() { seq.Split(seq.With(1), 0) })
+}
+
+func TestSplitConcurrentShards(t *testing.T) {
+	// Shards consumed on different goroutines must still get exactly their round-robin share, in order.
+	shards := seq.Split(seq.Range(0, 10000), 4)
+	got := make([][]int, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Go(func() {
+			got[i] = slices.Collect(shard)
+		})
+	}
+	wg.Wait()
+	for i, g := range got {
+		want := slices.Collect(seq.RangeStep(i, 10000, 4))
+		if !slices.Equal(g, want) {
+			t.Errorf("Split shard %d got %d elements, want %d in round-robin order", i, len(g), len(want))
+		}
+	}
+}
+
+func TestBridgeCancelWhileBlocked(t *testing.T) {
+	// An inner channel that is never closed must not hold up a canceled Bridge.
+	synctest.Test(t, func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		gens := make(chan (<-chan int), 1)
+		inner := make(chan int, 1)
+		inner <- 1
+		gens <- inner
+		got := slices.Collect(seq.Bridge(ctx, gens))
+		if !slices.Equal(got, []int{1}) {
+			t.Errorf("Bridge = %v, want [1]", got)
+		}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.Errorf("Bridge returned before the context expired")
+		}
+	})
+}
+
+func TestParallelForEachCancelAfterLastCall(t *testing.T) {
+	// Canceling the parent only once every element has been handed to a call must not turn success into an error.
+	for _, forEach := range []func(context.Context, iter.Seq[int], int, func(context.Context, int) error) error{
+		seq.ParallelForEach[int], seq.ParallelForEachAll[int],
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := forEach(ctx, seq.Range(0, 5), 2, func(_ context.Context, v int) error {
+			if v == 4 {
+				cancel()
+			}
+			return nil
+		})
+		cancel()
+		if err != nil {
+			t.Errorf("got %v after every call succeeded, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Package wire carries sequences out of a process and back in. Elements are encoded with a [Codec] and written as
+// length-delimited frames, either streamed to another process with [Serve] and consumed there with [Dial], or captured
+// as a fixture file with [Record] and replayed in tests with [Playback].
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"os"
+	"sync"
+)
+
+// Codec converts values of type T to and from bytes, so that values only need a serialization defined once to be
+// written to files or sent across connections. See [JSONCodec], [GobCodec], and [BytesCodec].
+type Codec[T any] interface {
+	Encode(T) ([]byte, error)
+	Decode([]byte) (T, error)
+}
+
+// JSONCodec is a [Codec] that encodes values as JSON using [encoding/json].
+type JSONCodec[T any] struct{}
+
+// Encode returns the JSON encoding of t.
+func (JSONCodec[T]) Encode(t T) ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Decode parses the JSON encoded data into a T.
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var t T
+	err := json.Unmarshal(data, &t)
+	return t, err
+}
+
+// GobCodec is a [Codec] that encodes values using [encoding/gob]. Each value is encoded independently, so the type
+// information is repeated for every value; prefer [JSONCodec] or a custom Codec for large numbers of small values.
+type GobCodec[T any] struct{}
+
+// Encode returns the gob encoding of t.
+func (GobCodec[T]) Encode(t T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode parses the gob encoded data into a T.
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var t T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&t)
+	return t, err
+}
+
+// BytesCodec is a [Codec] for raw byte slices. Values are passed through unchanged, without copying.
+type BytesCodec struct{}
+
+// Encode returns b unchanged.
+func (BytesCodec) Encode(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+// Decode returns data unchanged.
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// Record returns a sequence of the elements of the provided sequence, each paired with a nil error, that also writes
+// every element to the file at path using codec, so that a real run can be captured as a fixture for [Playback]. The
+// file is created, or truncated, when the returned sequence is iterated over, and each element is written before it is
+// yielded, so the fixture holds exactly the elements the consumer saw, even if it stops early. If an element can't be
+// encoded or written, it is yielded with the error and the sequence ends; if the file can't be created, flushed, or
+// closed, the error is yielded with the zero value of T, unless the consumer has already stopped, in which case it is
+// lost. Elements that encode to more than [MaxFrameSize] bytes fail with [ErrFrameTooLarge]. The provided sequence is
+// iterated over lazily when the returned sequence is iterated over.
+func Record[T any](seq iter.Seq[T], path string, codec Codec[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		f, err := os.Create(path)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		w := bufio.NewWriter(f)
+		write := func(t T) error {
+			data, err := codec.Encode(t)
+			if err != nil {
+				return err
+			}
+			return writeFrame(w, data)
+		}
+		stopped := false
+		for t := range seq {
+			if err := write(t); err != nil {
+				f.Close()
+				yield(t, err)
+				return
+			}
+			if !yield(t, nil) {
+				stopped = true
+				break
+			}
+		}
+		if err := cmp.Or(w.Flush(), f.Close()); err != nil && !stopped {
+			yield(zero, err)
+		}
+	}
+}
+
+// Playback returns a sequence of the elements recorded by [Record] in the file at path, decoded with codec and each
+// paired with a nil error. The file is opened when the returned sequence is iterated over, so the sequence can be
+// iterated over more than once. If the file can't be opened or read, holds an element larger than [MaxFrameSize], or
+// an element can't be decoded, the error is yielded with the zero value of T and the sequence ends.
+func Playback[T any](path string, codec Codec[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		f, err := os.Open(path)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer f.Close()
+		r := bufio.NewReader(f)
+		for {
+			data, err := readFrame(r)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			t, err := codec.Decode(data)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// MaxFrameSize is the largest encoded element, in bytes, that will be written or read as a frame. The limit keeps a
+// corrupt fixture, or a misbehaving server, from making the reader allocate an arbitrary amount of memory for one
+// element.
+const MaxFrameSize = 64 << 20
+
+// ErrFrameTooLarge is returned when an encoded element is larger than [MaxFrameSize].
+var ErrFrameTooLarge = errors.New("wire: frame larger than MaxFrameSize")
+
+// writeFrame writes data to w prefixed with its length as a uvarint.
+func writeFrame(w *bufio.Writer, data []byte) error {
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(data))
+	}
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFrame reads a frame written by writeFrame. It returns io.EOF if r is exhausted before the frame starts,
+// io.ErrUnexpectedEOF if it is exhausted part way through, and ErrFrameTooLarge, before allocating anything, if the
+// length prefix is over MaxFrameSize.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Frame tags of the protocol used by Serve and Dial. Every frame is a tag byte followed by a frame as written by
+// writeFrame.
+const (
+	tagValue byte = 'v' // an element encoded with the Codec
+	tagError byte = 'e' // the message of an error that ended the sequence on the serving side
+	tagEnd   byte = 'z' // the sequence was exhausted
+)
+
+// Serve accepts connections on the listener and streams a sequence to each of them, so that another process can consume
+// it with [Dial]. For every connection, seq is called with a context that is canceled once streaming to that connection
+// ends, either because the client went away or because Serve is returning. The elements of the sequence it returns are
+// encoded with codec and written as length-delimited frames, followed by an end marker. If an element can't be encoded,
+// or encodes to more than [MaxFrameSize] bytes, the error is sent to the client and the connection is closed.
+// Serve returns nil once the listener is closed, or the error if Accept fails for any other reason. Before returning it
+// cancels the contexts of the active connections and waits for them to finish.
+func Serve[T any](l net.Listener, seq func(context.Context) iter.Seq[T], codec Codec[T]) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		wg.Go(func() {
+			defer conn.Close()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			serveConn(ctx, conn, seq(ctx), codec)
+		})
+	}
+}
+
+// serveConn writes the elements of seq to w. It stops at the first write error.
+func serveConn[T any](ctx context.Context, w io.Writer, seq iter.Seq[T], codec Codec[T]) {
+	bw := bufio.NewWriter(w)
+	send := func(tag byte, data []byte) error {
+		if err := bw.WriteByte(tag); err != nil {
+			return err
+		}
+		if err := writeFrame(bw, data); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	for t := range seq {
+		data, err := codec.Encode(t)
+		if err == nil && len(data) > MaxFrameSize {
+			err = fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(data))
+		}
+		if err != nil {
+			send(tagError, []byte(err.Error()))
+			return
+		}
+		if send(tagValue, data) != nil {
+			return
+		}
+	}
+	if ctx.Err() == nil {
+		send(tagEnd, nil)
+	}
+}
+
+// Dial connects to the address on the named network, as with [net.Dialer.DialContext], and returns the sequence served
+// there by [Serve], decoding each element with codec and pairing it with a nil error. A new connection is made each
+// time the returned sequence is iterated over. If the connection can't be made, fails, or ends before the end of the
+// sequence, or an element can't be decoded, the error is yielded with the zero value of T and the sequence ends; errors
+// reported by the server are yielded the same way. A frame over [MaxFrameSize] bytes is rejected with
+// [ErrFrameTooLarge] before anything is allocated for it, so a misbehaving server can't exhaust the client's
+// memory. Canceling ctx closes the connection. Stopping iteration early closes the connection, which cancels the
+// server's sequence.
+func Dial[T any](ctx context.Context, network, addr string, codec Codec[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer conn.Close()
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		r := bufio.NewReader(conn)
+		for {
+			tag, err := r.ReadByte()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			var data []byte
+			if err == nil {
+				data, err = readFrame(r)
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+			}
+			if err != nil {
+				yield(zero, cmp.Or(ctx.Err(), err))
+				return
+			}
+			switch tag {
+			case tagValue:
+				t, err := codec.Decode(data)
+				if err != nil {
+					yield(zero, err)
+					return
+				}
+				if !yield(t, nil) {
+					return
+				}
+			case tagError:
+				yield(zero, fmt.Errorf("wire: server: %s", data))
+				return
+			case tagEnd:
+				return
+			default:
+				yield(zero, fmt.Errorf("wire: unknown frame tag %q", tag))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+func ExampleCodec() {
+	type point struct{ X, Y int }
+
+	roundTrip := func(c Codec[point]) {
+		b, err := c.Encode(point{X: 1, Y: 2})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		p, err := c.Decode(b)
+		fmt.Println(p, err)
+	}
+	roundTrip(JSONCodec[point]{})
+	roundTrip(GobCodec[point]{})
+
+	b, _ := JSONCodec[point]{}.Encode(point{X: 1, Y: 2})
+	fmt.Println(string(b))
+
+	raw, _ := BytesCodec{}.Decode([]byte("raw"))
+	fmt.Println(string(raw))
+
+	// Output:
+	// {1 2} <nil>
+	// {1 2} <nil>
+	// {"X":1,"Y":2}
+	// raw
+}
+
+func ExampleRecord() {
+	dir, err := os.MkdirTemp("", "wire-record")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "events.fixture")
+
+	type event struct {
+		ID   int
+		Kind string
+	}
+	// a real run: the events pass through to the consumer and are captured as they go
+	live := slices.Values([]event{{1, "login"}, {2, "view"}, {3, "logout"}})
+	for e, err := range Record(live, fixture, JSONCodec[event]{}) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("live", e.ID, e.Kind)
+	}
+
+	// in a test: replay the captured events
+	for e, err := range Playback(fixture, JSONCodec[event]{}) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("replayed", e.ID, e.Kind)
+	}
+
+	// Output:
+	// live 1 login
+	// live 2 view
+	// live 3 logout
+	// replayed 1 login
+	// replayed 2 view
+	// replayed 3 logout
+}
+
+func ExampleServe() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	served := make(chan error)
+	go func() {
+		served <- Serve(l, func(ctx context.Context) iter.Seq[string] {
+			return slices.Values([]string{"alpha", "beta", "gamma"})
+		}, JSONCodec[string]{})
+	}()
+
+	for v, err := range Dial(context.Background(), "tcp", l.Addr().String(), JSONCodec[string]{}) {
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(v)
+	}
+
+	l.Close()
+	fmt.Println(<-served)
+
+	// Output:
+	// alpha
+	// beta
+	// gamma
+	// <nil>
+}